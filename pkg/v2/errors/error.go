@@ -29,6 +29,30 @@ func (e *ErrorNotFound) Error() string {
 	return fmt.Sprintf("%s with %s %s not found", e.Resource, e.Field, e.Value)
 }
 
+type ErrorPreconditionFailed struct {
+	ErrorDetail
+}
+
+func (e *ErrorPreconditionFailed) Error() string {
+	return fmt.Sprintf("%s with %s %s was modified concurrently", e.Resource, e.Field, e.Value)
+}
+
+type ErrorConflict struct {
+	ErrorDetail
+}
+
+func (e *ErrorConflict) Error() string {
+	return fmt.Sprintf("%s with %s %s conflicts with a concurrent change", e.Resource, e.Field, e.Value)
+}
+
+type ErrorForbidden struct {
+	ErrorDetail
+}
+
+func (e *ErrorForbidden) Error() string {
+	return "Forbidden"
+}
+
 type ErrorBadRequest struct {
 	ErrorDetail
 }
@@ -118,6 +142,21 @@ func NewErrorResponse(err error) (*ErrorResponse, int) {
 			Message: "Resource already exists",
 			Errors:  []ErrorDetail{e.ErrorDetail},
 		}, 409
+	case *ErrorConflict:
+		return &ErrorResponse{
+			Message: "Conflict",
+			Errors:  []ErrorDetail{e.ErrorDetail},
+		}, 409
+	case *ErrorPreconditionFailed:
+		return &ErrorResponse{
+			Message: "Precondition failed",
+			Errors:  []ErrorDetail{e.ErrorDetail},
+		}, 412
+	case *ErrorForbidden:
+		return &ErrorResponse{
+			Message: "Forbidden",
+			Errors:  []ErrorDetail{e.ErrorDetail},
+		}, 403
 	case *ErrorSalechannelNotAllowed:
 		return &ErrorResponse{
 			Message: "Salechannel not allowed",
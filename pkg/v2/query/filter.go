@@ -81,7 +81,7 @@ func NewFiltersFromUrl(value *url.URL) ([]Filter, error) {
 	var filters []Filter
 	for key, values := range value.Query() {
 		if len(values) > 0 {
-			if shared.Contains(key, []string{"limit", "sort", "next", "prev"}) {
+			if shared.Contains(key, []string{"limit", "sort", "next", "prev", "fields"}) {
 				continue
 			}
 			for _, v := range values {
@@ -136,6 +136,12 @@ type QueryOptions struct {
 	OrderBy          string
 	OrderByDirection Direction
 	Filters          []Filter
+	// IncludeDeleted also returns soft deleted documents.
+	IncludeDeleted bool
+	// IncludeTotal makes Get count all matching documents into PaginationResult.Total.
+	IncludeTotal bool
+	// Fields restricts the fields Get reads to the given Firestore names. Empty reads whole documents.
+	Fields []string
 }
 
 func parseLimit(value string, maxLimit int, defaultLimit int) int {
@@ -169,6 +175,17 @@ func parseOrderBy(value string) (string, Direction) {
 	return value, direction
 }
 
+// parseFields splits a comma separated list of field names.
+func parseFields(value string) []string {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
 func NewQueryOptionsFromUrl(value *url.URL) (QueryOptions, error) {
 	q := value.Query()
 
@@ -176,6 +193,7 @@ func NewQueryOptionsFromUrl(value *url.URL) (QueryOptions, error) {
 	orderBy, orderDirection := parseOrderBy(q.Get("sort"))
 	next := q.Get("next")
 	previous := q.Get("prev")
+	fields := parseFields(q.Get("fields"))
 	filters, err := NewFiltersFromUrl(value)
 	if err != nil {
 		return QueryOptions{}, err
@@ -188,6 +206,7 @@ func NewQueryOptionsFromUrl(value *url.URL) (QueryOptions, error) {
 		OrderBy:          orderBy,
 		OrderByDirection: orderDirection,
 		Filters:          filters,
+		Fields:           fields,
 	}, nil
 }
 
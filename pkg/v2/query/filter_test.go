@@ -193,3 +193,22 @@ func TestQueryFormValues(t *testing.T) {
 		}
 	})
 }
+
+func TestQueryOptionsFields(t *testing.T) {
+	q, err := NewQueryOptionsFromUrlString("/users?fields=name,%20email,,address.city&active=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(q.Fields) != 3 || q.Fields[0] != "name" || q.Fields[1] != "email" || q.Fields[2] != "address.city" {
+		t.Errorf("Expected [name email address.city], got %v", q.Fields)
+	}
+	if len(q.Filters) != 1 || q.Filters[0].Field != "active" {
+		t.Errorf("Expected only the active filter, got %v", q.Filters)
+	}
+
+	q, _ = NewQueryOptionsFromUrlString("/users")
+	if q.Fields != nil {
+		t.Errorf("Expected no fields, got %v", q.Fields)
+	}
+}
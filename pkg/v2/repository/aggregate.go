@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+// Aggregation lists the fields to sum and average in Aggregate.
+type Aggregation struct {
+	Sum []string
+	Avg []string
+}
+
+// AggregationResult holds the number of matching documents and the requested sums and averages
+// by field. Non-numeric values are ignored; a field without any numeric value sums to 0 and has
+// no average.
+type AggregationResult struct {
+	Count int64              `json:"count"`
+	Sum   map[string]float64 `json:"sum,omitempty"`
+	Avg   map[string]float64 `json:"avg,omitempty"`
+}
+
+func newAggregationResult(count int64) *AggregationResult {
+	return &AggregationResult{
+		Count: count,
+		Sum:   map[string]float64{},
+		Avg:   map[string]float64{},
+	}
+}
+
+// aggregate computes agg over objs in memory, following the Firestore rules for non-numeric values.
+func aggregate[T any](objs []T, agg Aggregation) *AggregationResult {
+	res := newAggregationResult(int64(len(objs)))
+	for _, field := range agg.Sum {
+		var sum float64
+		for _, obj := range objs {
+			if value, ok := numericPath(obj, field); ok {
+				sum += value
+			}
+		}
+		res.Sum[field] = sum
+	}
+	for _, field := range agg.Avg {
+		var sum float64
+		var n int
+		for _, obj := range objs {
+			if value, ok := numericPath(obj, field); ok {
+				sum += value
+				n++
+			}
+		}
+		if n > 0 {
+			res.Avg[field] = sum / float64(n)
+		}
+	}
+	return res
+}
+
+func numericPath(obj interface{}, path string) (float64, bool) {
+	value, ok := lookupPath(reflect.ValueOf(obj), path)
+	if !ok {
+		return 0, false
+	}
+	value, ok = indirect(value)
+	if !ok {
+		return 0, false
+	}
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	return 0, false
+}
+
+// aggregationQuery applies the filters of opts to an aggregation query counting the documents.
+func (r *repository[T, TT]) aggregationQuery(opts *query.QueryOptions) (*firestore.AggregationQuery, error) {
+	if opts == nil {
+		opts = &query.QueryOptions{}
+	}
+	opts, err := sealQuery[T](r.opts, r.Ressource, opts)
+	if err != nil {
+		return nil, err
+	}
+	q := r.filter(r.query(), opts)
+	return q.NewAggregationQuery().WithCount("count"), nil
+}
+
+// Count returns the number of documents matching the filters of opts, ignoring its limit and cursors.
+func (r *repository[T, TT]) Count(ctx context.Context, opts *query.QueryOptions) (int64, error) {
+	q, err := r.aggregationQuery(opts)
+	if err != nil {
+		return 0, err
+	}
+	res, err := r.aggregate(ctx, q)
+	if err != nil {
+		return 0, r.translateError("", err)
+	}
+	return aggregationCount(res["count"]), nil
+}
+
+// Aggregate counts the documents matching the filters of opts and sums and averages the fields of
+// agg on the server. Firestore allows at most five aggregations per query including the count.
+func (r *repository[T, TT]) Aggregate(ctx context.Context, opts *query.QueryOptions, agg Aggregation) (*AggregationResult, error) {
+	q, err := r.aggregationQuery(opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, field := range agg.Sum {
+		q = q.WithSum(field, "sum_"+strconv.Itoa(i))
+	}
+	for i, field := range agg.Avg {
+		q = q.WithAvg(field, "avg_"+strconv.Itoa(i))
+	}
+	values, err := r.aggregate(ctx, q)
+	if err != nil {
+		return nil, r.translateError("", err)
+	}
+
+	res := newAggregationResult(aggregationCount(values["count"]))
+	for i, field := range agg.Sum {
+		res.Sum[field], _ = aggregationValue(values["sum_"+strconv.Itoa(i)])
+	}
+	for i, field := range agg.Avg {
+		if avg, ok := aggregationValue(values["avg_"+strconv.Itoa(i)]); ok {
+			res.Avg[field] = avg
+		}
+	}
+	return res, nil
+}
+
+func aggregationCount(value interface{}) int64 {
+	v, _ := value.(*firestorepb.Value)
+	return v.GetIntegerValue()
+}
+
+// aggregationValue converts a value of a Firestore aggregation result. Averages without numeric
+// values are null and reported as not ok.
+func aggregationValue(value interface{}) (float64, bool) {
+	v, ok := value.(*firestorepb.Value)
+	if !ok {
+		return 0, false
+	}
+	switch x := v.GetValueType().(type) {
+	case *firestorepb.Value_IntegerValue:
+		return float64(x.IntegerValue), true
+	case *firestorepb.Value_DoubleValue:
+		return x.DoubleValue, true
+	}
+	return 0, false
+}
+
+// total returns the total of matching documents for opts.IncludeTotal, or nil if it is not set.
+func total(ctx context.Context, opts *query.QueryOptions, count func(context.Context, *query.QueryOptions) (int64, error)) (*int64, error) {
+	if !opts.IncludeTotal {
+		return nil, nil
+	}
+	n, err := count(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+func TestMemoryRepositoryAggregate(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User")
+	for _, user := range []*testUser{
+		{Name: "A", Email: "a@example.com", Age: 20},
+		{Name: "B", Email: "b@example.com", Age: 30},
+		{Name: "C", Email: "c@example.com", Age: 50},
+	} {
+		if _, err := repo.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	adults := &query.QueryOptions{Filters: []query.Filter{{Field: "age", Operator: query.Gte, Value: 30}}}
+
+	count, err := repo.Count(ctx, adults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 matching documents, got %d", count)
+	}
+
+	res, err := repo.Aggregate(ctx, adults, Aggregation{Sum: []string{"age", "name"}, Avg: []string{"age", "name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Count != 2 || res.Sum["age"] != 80 || res.Avg["age"] != 40 {
+		t.Errorf("Expected count 2, sum 80 and avg 40, got %+v", res)
+	}
+	if _, ok := res.Avg["name"]; ok || res.Sum["name"] != 0 {
+		t.Errorf("Expected non-numeric fields to sum to 0 without an average, got %+v", res)
+	}
+
+	page, err := repo.Get(ctx, &query.QueryOptions{Limit: 1, IncludeTotal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total == nil || *page.Total != 3 || len(page.Items) != 1 {
+		t.Errorf("Expected one item and a total of 3, got %d items and total %v", len(page.Items), page.Total)
+	}
+	if page, _ := repo.Get(ctx, nil); page.Total != nil {
+		t.Errorf("Expected no total unless requested, got %d", *page.Total)
+	}
+}
+
+func TestSqlAggregateQuery(t *testing.T) {
+	table := newSqlTable("users", PostgresDialect, reflect.TypeOf(&testSqlUser{}))
+	table.SoftDelete = "deleted_at"
+
+	stmt, args, err := table.aggregateQuery(&query.QueryOptions{
+		Filters: []query.Filter{{Field: "email", Operator: query.Eq, Value: "a"}},
+	}, Aggregation{Sum: []string{"age"}, Avg: []string{"age"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT COUNT(*), SUM("age"), AVG("age") FROM "users" WHERE "email" = $1 AND "deleted_at" IS NULL`
+	if stmt != want {
+		t.Errorf("Expected %s, got %s", want, stmt)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"a"}) {
+		t.Errorf("Expected args [a], got %v", args)
+	}
+
+	if _, _, err := table.aggregateQuery(&query.QueryOptions{}, Aggregation{Sum: []string{"unknown"}}); err == nil {
+		t.Errorf("Expected an error for an unknown field")
+	}
+}
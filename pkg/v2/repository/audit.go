@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+// AuditOperation names the kind of write an AuditRecord describes.
+type AuditOperation string
+
+const (
+	AuditCreate  AuditOperation = "create"
+	AuditUpdate  AuditOperation = "update"
+	AuditDelete  AuditOperation = "delete"
+	AuditRestore AuditOperation = "restore"
+	AuditPurge   AuditOperation = "purge"
+)
+
+// AuditRecord is one entry of an entity's change history.
+type AuditRecord struct {
+	ID        string                 `json:"id" firestore:"-"`
+	EntityID  string                 `json:"entity_id" firestore:"entity_id"`
+	Actor     string                 `json:"actor" firestore:"actor"`
+	Operation AuditOperation         `json:"operation" firestore:"operation"`
+	Timestamp time.Time              `json:"timestamp" firestore:"timestamp"`
+	Changes   map[string]AuditChange `json:"changes" firestore:"changes"`
+}
+
+// AuditChange holds the values of one field before and after a write. Before is nil for
+// created fields, After for removed ones.
+type AuditChange struct {
+	Before interface{} `json:"before" firestore:"before"`
+	After  interface{} `json:"after" firestore:"after"`
+}
+
+func (a *AuditRecord) DocId() string {
+	return a.ID
+}
+
+func (a *AuditRecord) SetDocId(id string) {
+	a.ID = id
+}
+
+func (a *AuditRecord) UniqFields() map[string]interface{} {
+	return nil
+}
+
+type actorKey struct{}
+
+// ContextWithActor returns a copy of ctx carrying the actor recorded in audit records.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set with ContextWithActor.
+func ActorFromContext(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+func newAuditRecord(ctx context.Context, id string, op AuditOperation, changes map[string]AuditChange) *AuditRecord {
+	actor, _ := ActorFromContext(ctx)
+	return &AuditRecord{
+		EntityID:  id,
+		Actor:     actor,
+		Operation: op,
+		Timestamp: time.Now(),
+		Changes:   changes,
+	}
+}
+
+// historyOptions orders history newest first unless opts sets an order.
+func historyOptions(opts *query.QueryOptions) *query.QueryOptions {
+	if opts == nil {
+		opts = &query.QueryOptions{
+			Limit: 100,
+		}
+	}
+	if opts.OrderBy != "" {
+		return opts
+	}
+	ordered := *opts
+	ordered.OrderBy = "timestamp"
+	ordered.OrderByDirection = query.Desc
+	return &ordered
+}
+
+// createdChanges lists the non-zero fields of a new entity.
+func createdChanges(obj interface{}) map[string]AuditChange {
+	changes := map[string]AuditChange{}
+	for field, value := range entityFields(obj) {
+		changes[field] = AuditChange{After: value}
+	}
+	return changes
+}
+
+// removedChanges lists the non-zero fields of a purged entity.
+func removedChanges(fields map[string]interface{}) map[string]AuditChange {
+	changes := make(map[string]AuditChange, len(fields))
+	for field, value := range fields {
+		if !isZeroValue(value) {
+			changes[field] = AuditChange{Before: value}
+		}
+	}
+	return changes
+}
+
+// updatedChanges pairs the written values of data with the stored ones returned by before.
+// Field deletions are recorded with a nil After.
+func updatedChanges(data map[string]interface{}, before func(path string) interface{}) map[string]AuditChange {
+	changes := make(map[string]AuditChange, len(data))
+	for path, value := range data {
+		if value == firestore.Delete {
+			value = nil
+		}
+		changes[path] = AuditChange{Before: before(path), After: value}
+	}
+	return changes
+}
+
+// entityValue reads paths of obj, returning nil for missing fields.
+func entityValue(obj interface{}) func(path string) interface{} {
+	return func(path string) interface{} {
+		value, ok := lookupPath(reflect.ValueOf(obj), path)
+		if !ok {
+			return nil
+		}
+		return value.Interface()
+	}
+}
+
+// entityFields returns the non-zero top level fields of obj by their Firestore names.
+func entityFields(obj interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	val, ok := indirect(reflect.ValueOf(obj))
+	if !ok || val.Kind() != reflect.Struct {
+		return fields
+	}
+	for _, f := range structFields(val.Type()) {
+		fv, err := val.FieldByIndexErr(f.Index)
+		if err != nil || fv.IsZero() {
+			continue
+		}
+		fields[f.Name] = fv.Interface()
+	}
+	return fields
+}
+
+// history returns a repository over the collection holding the audit records of the document id.
+func (r *repository[T, TT]) history(id string) *repository[*AuditRecord, AuditRecord] {
+	history := &repository[*AuditRecord, AuditRecord]{
+		Db:         r.Db,
+		Parent:     r.Parent,
+		Collection: r.Collection + "_history",
+		Ressource:  r.Ressource + "History",
+		opts:       newOptions(nil),
+	}
+	switch {
+	case r.opts.auditSubcollection:
+		if r.opts.auditCollection != "" {
+			history.Collection = r.opts.auditCollection
+		}
+		history.Parent = r.collection().Doc(id)
+	case r.opts.auditCollection != "":
+		// A shared audit collection is separated per environment like the entity collections.
+		history.Collection = r.opts.collectionPrefix + r.opts.auditCollection + r.opts.collectionSuffix
+	}
+	return history
+}
+
+// audit adds the record of op on the document id to tx if auditing is enabled.
+func (r *repository[T, TT]) audit(ctx context.Context, tx *txn, id string, op AuditOperation, changes map[string]AuditChange) error {
+	if !r.opts.audit {
+		return nil
+	}
+	return tx.Create(r.history(id).collection().NewDoc(), newAuditRecord(ctx, id, op, changes))
+}
+
+// History lists the audit records of the document id, newest first unless opts sets an order.
+func (r *repository[T, TT]) History(ctx context.Context, id string, opts *query.QueryOptions) (*PaginationResult[*AuditRecord], error) {
+	if id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	if !r.opts.audit {
+		return nil, fmt.Errorf("audit is not enabled for %s", r.Ressource)
+	}
+
+	history := r.history(id)
+	opts = historyOptions(opts)
+	if r.opts.auditSubcollection {
+		return history.Get(ctx, opts)
+	}
+
+	filtered := *opts
+	filtered.Filters = append(slices.Clone(opts.Filters), query.Filter{Field: "entity_id", Operator: query.Eq, Value: id})
+	res, err := history.Get(ctx, &filtered)
+	if err != nil {
+		return nil, err
+	}
+	res.Filters = &opts.Filters
+	return res, nil
+}
+
+// snapshotValue reads paths of doc, returning nil for missing fields.
+func snapshotValue(doc *firestore.DocumentSnapshot) func(path string) interface{} {
+	return func(path string) interface{} {
+		value, err := doc.DataAt(path)
+		if err != nil {
+			return nil
+		}
+		return value
+	}
+}
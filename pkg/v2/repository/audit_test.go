@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+func TestMemoryRepositoryAudit(t *testing.T) {
+	ctx := ContextWithActor(context.Background(), "alice")
+	repo := NewMemoryRepository[*testUser, testUser]("User", WithAudit(), WithSoftDelete())
+
+	id, err := repo.Create(ctx, &testUser{Email: "a@example.com", Name: "A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Update(ctx, *id, map[string]interface{}{"name": "B"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, *id); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Restore(ctx, *id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(ctx, &testUser{Email: "b@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := repo.History(ctx, *id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AuditOperation{AuditRestore, AuditDelete, AuditUpdate, AuditCreate}
+	if len(page.Items) != len(want) {
+		t.Fatalf("Expected %d records of the document, got %d", len(want), len(page.Items))
+	}
+	for i, record := range page.Items {
+		if record.Operation != want[i] || record.EntityID != *id || record.Actor != "alice" {
+			t.Errorf("Expected %s by alice on %s, got %+v", want[i], *id, record)
+		}
+	}
+
+	update := page.Items[2].Changes["name"]
+	if update.Before != "A" || update.After != "B" {
+		t.Errorf("Expected name change A -> B, got %+v", update)
+	}
+	created := page.Items[3].Changes
+	if created["email"].After != "a@example.com" || created["email"].Before != nil {
+		t.Errorf("Expected created fields in the create record, got %+v", created)
+	}
+	if _, ok := created["age"]; ok {
+		t.Errorf("Expected zero fields to be left out of the create record")
+	}
+
+	first, err := repo.History(ctx, *id, &query.QueryOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := repo.History(ctx, *id, &query.QueryOptions{Limit: 2, Next: first.Next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Items) != 2 || second.Items[1].Operation != AuditCreate {
+		t.Errorf("Expected the second page to end with the create record, got %+v", second.Items)
+	}
+
+	if err := repo.Purge(ctx, *id); err != nil {
+		t.Fatal(err)
+	}
+	page, _ = repo.History(ctx, *id, &query.QueryOptions{Limit: 1})
+	if page.Items[0].Operation != AuditPurge || page.Items[0].Changes["email"].Before != "a@example.com" {
+		t.Errorf("Expected the purge to keep the history and record the removed fields, got %+v", page.Items[0])
+	}
+
+	if _, err := NewMemoryRepository[*testUser, testUser]("User").History(ctx, *id, nil); err == nil {
+		t.Errorf("Expected History to fail without audit")
+	}
+}
+
+func TestAuditCollectionName(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"default", []Option{WithAudit(), WithCollectionPrefix("staging_")}, "staging_users_history"},
+		{"shared", []Option{WithAuditCollection("audit_log")}, "audit_log"},
+		{"shared with prefix", []Option{WithAuditCollection("audit_log"), WithCollectionPrefix("staging_"), WithCollectionSuffix("_v2")}, "staging_audit_log_v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewFirebaseRepository[*testUser, testUser](nil, "User", tt.opts...).(*repository[*testUser, testUser])
+			if got := repo.history("id").Collection; got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSqlRepositoryRejectsAudit(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSqlRepository[*testUser, testUser](nil, "User", SqliteDialect, WithAudit())
+	if _, err := repo.Create(ctx, &testUser{Email: "a@example.com"}); !stderrors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported from Create, got %v", err)
+	}
+	if err := repo.Update(ctx, "id", map[string]interface{}{"name": "A"}); !stderrors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported from Update, got %v", err)
+	}
+	if _, err := repo.DeleteMany(ctx, []string{"id"}); !stderrors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported from DeleteMany, got %v", err)
+	}
+}
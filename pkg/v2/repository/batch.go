@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"cloud.google.com/go/firestore"
+)
+
+// uniqueIDs returns ids without duplicates, keeping the first occurrence of each.
+func uniqueIDs(ids []string) ([]string, error) {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" {
+			return nil, errIDRequired
+		}
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique, nil
+}
+
+// inOrder returns the entities of found in the order of ids together with the IDs not found.
+func inOrder[T any](ids []string, found map[string]T) ([]T, []string) {
+	objs := make([]T, 0, len(found))
+	var missing []string
+	for _, id := range ids {
+		if obj, ok := found[id]; ok {
+			objs = append(objs, obj)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return objs, missing
+}
+
+// GetByIDs loads the documents of ids with a single Firestore GetAll. The entities are returned
+// in the order of ids, each at most once; IDs without a document, or with a soft deleted one,
+// are returned as missing.
+func (r *repository[T, TT]) GetByIDs(ctx context.Context, ids []string) ([]T, []string, error) {
+	ids, err := uniqueIDs(ids)
+	if err != nil || len(ids) == 0 {
+		return nil, nil, err
+	}
+
+	refs := make([]*firestore.DocumentRef, len(ids))
+	for i, id := range ids {
+		refs[i] = r.collection().Doc(id)
+	}
+	docs, err := r.getDocs(ctx, refs)
+	if err != nil {
+		return nil, nil, r.translateError("", err)
+	}
+
+	found := make(map[string]T, len(docs))
+	for _, doc := range docs {
+		if !doc.Exists() || r.isDeleted(doc) {
+			continue
+		}
+		obj, err := r.decode(ctx, doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		found[doc.Ref.ID] = *obj
+	}
+	objs, missing := inOrder(ids, found)
+	return objs, missing, nil
+}
+
+// BatchGetter loads entities by ID. Every Repository is a BatchGetter.
+type BatchGetter[T Entity] interface {
+	GetByIDs(ctx context.Context, ids []string) ([]T, []string, error)
+}
+
+// reference is a field holding the ID, or IDs, of entities and the field the entities are loaded into.
+type reference struct {
+	id     reflect.StructField
+	target reflect.StructField
+}
+
+// Preload resolves reference fields of objs to the entities of repo with a single GetByIDs call.
+// A reference is an ID field tagged with the name of the field that receives the entity:
+//
+//	CustomerID string    `firestore:"customer_id" ref:"Customer"`
+//	Customer   *Customer `firestore:"-"`
+//
+// A []string ID field fills a slice of entities. fields selects the receiving fields to load;
+// without fields every reference whose receiving field fits the entities of repo is loaded.
+// References to missing documents are left unset.
+func Preload[T any, R Entity](ctx context.Context, objs []T, repo BatchGetter[R], fields ...string) error {
+	refs, err := references(reflect.TypeOf((*T)(nil)).Elem(), reflect.TypeOf((*R)(nil)).Elem(), fields)
+	if err != nil || len(refs) == 0 {
+		return err
+	}
+
+	var ids []string
+	for _, obj := range objs {
+		val, ok := indirect(reflect.ValueOf(obj))
+		if !ok {
+			continue
+		}
+		for _, ref := range refs {
+			v, err := val.FieldByIndexErr(ref.id.Index)
+			if err != nil {
+				continue
+			}
+			switch v.Kind() {
+			case reflect.String:
+				if v.String() != "" {
+					ids = append(ids, v.String())
+				}
+			case reflect.Slice:
+				for i := 0; i < v.Len(); i++ {
+					if id := v.Index(i).String(); id != "" {
+						ids = append(ids, id)
+					}
+				}
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	loaded, _, err := repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]reflect.Value, len(loaded))
+	for _, obj := range loaded {
+		byID[obj.DocId()] = reflect.ValueOf(obj)
+	}
+
+	for _, obj := range objs {
+		val, ok := indirect(reflect.ValueOf(obj))
+		if !ok {
+			continue
+		}
+		for _, ref := range refs {
+			id, err := val.FieldByIndexErr(ref.id.Index)
+			if err != nil {
+				continue
+			}
+			target, err := val.FieldByIndexErr(ref.target.Index)
+			if err != nil {
+				continue
+			}
+			if id.Kind() == reflect.String {
+				if entity, ok := byID[id.String()]; ok {
+					target.Set(assignable(entity, target.Type()))
+				}
+				continue
+			}
+			entities := reflect.MakeSlice(target.Type(), 0, id.Len())
+			for i := 0; i < id.Len(); i++ {
+				if entity, ok := byID[id.Index(i).String()]; ok {
+					entities = reflect.Append(entities, assignable(entity, target.Type().Elem()))
+				}
+			}
+			target.Set(entities)
+		}
+	}
+	return nil
+}
+
+// references returns the reference fields of t whose receiving field takes entities of type
+// entity, restricted to the receiving fields named by fields if any are given.
+func references(t, entity reflect.Type, fields []string) ([]reference, error) {
+	if t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot preload references of %s, a struct pointer is required", t)
+	}
+	t = t.Elem()
+
+	var refs []reference
+	found := map[string]bool{}
+	for _, f := range reflect.VisibleFields(t) {
+		name := f.Tag.Get("ref")
+		if name == "" || (len(fields) > 0 && !slices.Contains(fields, name)) {
+			continue
+		}
+		target, ok := t.FieldByName(name)
+		if !ok {
+			return nil, fmt.Errorf("reference %s of %s: no field %s", f.Name, t, name)
+		}
+		fits := false
+		switch {
+		case f.Type.Kind() == reflect.String:
+			fits = accepts(target.Type, entity)
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.String:
+			fits = target.Type.Kind() == reflect.Slice && accepts(target.Type.Elem(), entity)
+		default:
+			return nil, fmt.Errorf("reference %s of %s must be a string or []string", f.Name, t)
+		}
+		if !fits {
+			if len(fields) > 0 {
+				return nil, fmt.Errorf("reference %s of %s cannot hold %s", name, t, entity)
+			}
+			continue
+		}
+		refs = append(refs, reference{id: f, target: target})
+		found[name] = true
+	}
+	for _, name := range fields {
+		if !found[name] {
+			return nil, fmt.Errorf("%s has no reference %s", t, name)
+		}
+	}
+	return refs, nil
+}
+
+// accepts reports whether a field of type dst can hold an entity of type entity or the struct it points to.
+func accepts(dst, entity reflect.Type) bool {
+	return entity.AssignableTo(dst) || (entity.Kind() == reflect.Pointer && entity.Elem().AssignableTo(dst))
+}
+
+// assignable returns entity, or the struct it points to, as a value for a field of type dst.
+func assignable(entity reflect.Value, dst reflect.Type) reflect.Value {
+	if entity.Type().AssignableTo(dst) {
+		return entity
+	}
+	return entity.Elem()
+}
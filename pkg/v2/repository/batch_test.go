@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testOrder struct {
+	ID         string      `firestore:"-"`
+	CustomerID string      `firestore:"customer_id" ref:"Customer"`
+	Customer   *testUser   `firestore:"-"`
+	WatcherIDs []string    `firestore:"watcher_ids" ref:"Watchers"`
+	Watchers   []*testUser `firestore:"-"`
+}
+
+func TestMemoryRepositoryGetByIDs(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User")
+	ids := seedUsers(t, repo,
+		&testUser{Name: "A", Email: "a@example.com"},
+		&testUser{Name: "B", Email: "b@example.com"},
+	)
+
+	objs, missing, err := repo.GetByIDs(ctx, []string{ids[1], "unknown", ids[0], ids[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 || objs[0].Name != "B" || objs[1].Name != "A" || objs[0].ID != ids[1] {
+		t.Errorf("Expected B and A in input order, got %+v", objs)
+	}
+	if !reflect.DeepEqual(missing, []string{"unknown"}) {
+		t.Errorf("Expected unknown to be missing, got %v", missing)
+	}
+	if _, _, err := repo.GetByIDs(ctx, []string{""}); err == nil {
+		t.Errorf("Expected an error for an empty ID")
+	}
+
+	cached := NewCachedRepository[*testUser, testUser](repo, NewLRUCache(10, time.Minute))
+	cached.GetByID(ctx, ids[0])
+	if objs, _, err := cached.GetByIDs(ctx, ids); err != nil || len(objs) != 2 {
+		t.Fatalf("Expected both users from the cached repository, got %v, %v", objs, err)
+	}
+	if stats := cached.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Expected one hit and two misses, got %+v", stats)
+	}
+}
+
+func TestPreload(t *testing.T) {
+	ctx := context.Background()
+	users := NewMemoryRepository[*testUser, testUser]("User")
+	ids := seedUsers(t, users,
+		&testUser{Name: "A", Email: "a@example.com"},
+		&testUser{Name: "B", Email: "b@example.com"},
+	)
+	orders := []*testOrder{
+		{CustomerID: ids[0], WatcherIDs: []string{ids[1], "unknown", ids[0]}},
+		{CustomerID: ids[1]},
+		{CustomerID: "unknown"},
+	}
+
+	if err := Preload(ctx, orders, users); err != nil {
+		t.Fatal(err)
+	}
+	if orders[0].Customer == nil || orders[0].Customer.Name != "A" || orders[1].Customer.Name != "B" {
+		t.Errorf("Expected the customers to be loaded, got %+v, %+v", orders[0].Customer, orders[1].Customer)
+	}
+	if orders[2].Customer != nil {
+		t.Errorf("Expected a missing customer to stay unset, got %+v", orders[2].Customer)
+	}
+	if len(orders[0].Watchers) != 2 || orders[0].Watchers[0].Name != "B" {
+		t.Errorf("Expected the watchers B and A, got %+v", orders[0].Watchers)
+	}
+
+	if err := Preload(ctx, orders, users, "Unknown"); err == nil {
+		t.Errorf("Expected an error for an unknown reference")
+	}
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// BulkResult is the outcome of one item of CreateMany, UpdateMany or DeleteMany.
+// Results are returned in input order; a failed item does not abort the others.
+type BulkResult struct {
+	ID  string
+	Err error
+}
+
+// BulkUpdate is one item of UpdateMany.
+type BulkUpdate struct {
+	ID   string
+	Data map[string]interface{}
+}
+
+const defaultBulkConcurrency = 10
+
+var errIDRequired = fmt.Errorf("id is required")
+
+// runConcurrent calls fn for every index in [0, n) with at most concurrency calls in flight.
+func runConcurrent(n, concurrency int, fn func(i int)) {
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// bulkEach runs op for every index in [0, n) with at most concurrency calls in flight and collects the results.
+func bulkEach(n, concurrency int, op func(i int) (string, error)) []BulkResult {
+	results := make([]BulkResult, n)
+	runConcurrent(n, concurrency, func(i int) {
+		results[i].ID, results[i].Err = op(i)
+	})
+	return results
+}
+
+func allIndexes(n int) []int {
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// partition splits indexes into at most parts contiguous chunks of similar size.
+func partition(indexes []int, parts int) [][]int {
+	if len(indexes) == 0 {
+		return nil
+	}
+	size := (len(indexes) + max(parts, 1) - 1) / max(parts, 1)
+	chunks := make([][]int, 0, parts)
+	for len(indexes) > 0 {
+		n := min(size, len(indexes))
+		chunks = append(chunks, indexes[:n])
+		indexes = indexes[n:]
+	}
+	return chunks
+}
+
+// stampUpdatedAt returns a copy of data with the entity's UpdatedAt field set to now, unless data already sets it.
+func stampUpdatedAt(t reflect.Type, data map[string]interface{}) map[string]interface{} {
+	stamped := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		stamped[k] = v
+	}
+	for _, f := range structFields(t) {
+		if f.GoName != "UpdatedAt" || f.Type != timeType {
+			continue
+		}
+		if _, set := stamped[f.Name]; !set {
+			stamped[f.Name] = time.Now()
+		}
+		break
+	}
+	return stamped
+}
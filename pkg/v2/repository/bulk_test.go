@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+func TestPartition(t *testing.T) {
+	tests := []struct {
+		n     int
+		parts int
+		want  [][]int
+	}{
+		{0, 3, nil},
+		{5, 2, [][]int{{0, 1, 2}, {3, 4}}},
+		{2, 5, [][]int{{0}, {1}}},
+		{3, 0, [][]int{{0, 1, 2}}},
+	}
+	for _, tt := range tests {
+		if got := partition(allIndexes(tt.n), tt.parts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("partition(%d, %d) = %v, want %v", tt.n, tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryRepositoryBulk(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User", WithBulkConcurrency(2))
+
+	users := []*testUser{{Email: "a@example.com"}, {Email: "b@example.com"}, {Email: "c@example.com"}}
+	created, err := repo.CreateMany(ctx, users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, res := range created {
+		if res.Err != nil || res.ID == "" {
+			t.Fatalf("Unexpected result %d: %+v", i, res)
+		}
+		if users[i].CreatedAt.IsZero() {
+			t.Errorf("Expected CreatedAt to be set on %d", i)
+		}
+	}
+
+	before := users[0].UpdatedAt
+	updated, err := repo.UpdateMany(ctx, []BulkUpdate{
+		{ID: created[0].ID, Data: map[string]interface{}{"name": "A"}},
+		{ID: "missing", Data: map[string]interface{}{"name": "X"}},
+		{ID: created[1].ID, Data: map[string]interface{}{"name": "B"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated[0].Err != nil || updated[2].Err != nil {
+		t.Errorf("Expected existing documents to be updated, got %+v", updated)
+	}
+	if _, ok := updated[1].Err.(*errors.ErrorNotFound); !ok || updated[1].ID != "missing" {
+		t.Errorf("Expected ErrorNotFound for the missing document, got %+v", updated[1])
+	}
+	got, _ := repo.GetByID(ctx, created[0].ID)
+	if (*got).Name != "A" || !(*got).UpdatedAt.After(before) {
+		t.Errorf("Expected name and UpdatedAt to change, got %+v", *got)
+	}
+
+	deleted, err := repo.DeleteMany(ctx, []string{created[0].ID, "", created[2].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted[0].Err != nil || deleted[1].Err == nil || deleted[2].Err != nil {
+		t.Errorf("Unexpected delete results %+v", deleted)
+	}
+	res, _ := repo.Get(ctx, &query.QueryOptions{Limit: 10})
+	if len(res.Items) != 1 || res.Items[0].ID != created[1].ID {
+		t.Errorf("Expected only %s to remain, got %+v", created[1].ID, res.Items)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := repo.CreateMany(cancelled, users); err == nil {
+		t.Errorf("Expected CreateMany to fail on a cancelled context")
+	}
+}
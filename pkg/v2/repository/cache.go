@@ -0,0 +1,315 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache stores entities of a CachedRepository by document ID. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{})
+	Delete(key string)
+}
+
+// CacheStats counts the IDs requested by GetByID and GetByIDs that were answered from the cache and
+// those loaded from the repository.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List
+	items map[string]*list.Element
+}
+
+// NewLRUCache returns an in-process Cache holding at most size entries, evicting the least
+// recently used one first. Entries expire after ttl; a ttl of 0 keeps them until evicted.
+func NewLRUCache(size int, ttl time.Duration) Cache {
+	return &lruCache{
+		size:  max(size, 1),
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *lruCache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+	if el, ok := c.items[key]; ok {
+		el.Value = &lruEntry{key: key, value: value, expires: expires}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *lruCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+func (c *lruCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*lruEntry).key)
+}
+
+// flight is a GetByID call in progress that concurrent misses of the same ID wait for.
+type flight[T any] struct {
+	done chan struct{}
+	obj  *T
+	err  error
+}
+
+// CachedRepository serves GetByID from a Cache and passes every other call to the wrapped
+// repository. Writes through the same instance evict the affected IDs, writes through other
+// instances or processes are only picked up once the entry expires.
+type CachedRepository[T Entity, TT any] struct {
+	Repository[T, TT]
+	cache Cache
+
+	mu         sync.Mutex
+	flights    map[string]*flight[T]
+	generation uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewCachedRepository wraps repo with a read-through cache for GetByID. When combined with
+// NewTenantRepository the tenant repository must wrap the cached one, so that cached entities
+// are still checked against the tenant of each call.
+func NewCachedRepository[T Entity, TT any](repo Repository[T, TT], cache Cache) *CachedRepository[T, TT] {
+	return &CachedRepository[T, TT]{
+		Repository: repo,
+		cache:      cache,
+		flights:    make(map[string]*flight[T]),
+	}
+}
+
+func (r *CachedRepository[T, TT]) configuration() *options {
+	return configurationOf(r.Repository)
+}
+
+// Stats returns the hit and miss counters of GetByID.
+func (r *CachedRepository[T, TT]) Stats() CacheStats {
+	return CacheStats{
+		Hits:   r.hits.Load(),
+		Misses: r.misses.Load(),
+	}
+}
+
+// GetByID returns a copy of the cached entity or loads it. Concurrent misses of the same ID share
+// a single load; errors are not cached. In a unit of work the entity is always read in the
+// transaction.
+func (r *CachedRepository[T, TT]) GetByID(ctx context.Context, id string) (*T, error) {
+	if inTransaction(ctx) {
+		return r.Repository.GetByID(ctx, id)
+	}
+	if value, ok := r.cache.Get(id); ok {
+		if obj, ok := value.(T); ok {
+			r.hits.Add(1)
+			obj = cloneEntity(obj)
+			return &obj, nil
+		}
+	}
+	r.misses.Add(1)
+
+	r.mu.Lock()
+	f, ok := r.flights[id]
+	if !ok {
+		f = &flight[T]{done: make(chan struct{})}
+		r.flights[id] = f
+		go r.load(context.WithoutCancel(ctx), id, f, r.generation)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-f.done:
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	obj := cloneEntity(*f.obj)
+	return &obj, nil
+}
+
+// GetByIDs returns copies of the cached entities and loads the others with a single GetByIDs call
+// of the wrapped repository.
+func (r *CachedRepository[T, TT]) GetByIDs(ctx context.Context, ids []string) ([]T, []string, error) {
+	if inTransaction(ctx) {
+		return r.Repository.GetByIDs(ctx, ids)
+	}
+	ids, err := uniqueIDs(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	found := make(map[string]T, len(ids))
+	var misses []string
+	for _, id := range ids {
+		if value, ok := r.cache.Get(id); ok {
+			if obj, ok := value.(T); ok {
+				found[id] = cloneEntity(obj)
+				continue
+			}
+		}
+		misses = append(misses, id)
+	}
+	r.hits.Add(uint64(len(ids) - len(misses)))
+	r.misses.Add(uint64(len(misses)))
+
+	if len(misses) > 0 {
+		r.mu.Lock()
+		generation := r.generation
+		r.mu.Unlock()
+
+		loaded, _, err := r.Repository.GetByIDs(ctx, misses)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.mu.Lock()
+		for _, obj := range loaded {
+			if r.generation == generation {
+				r.cache.Set(obj.DocId(), cloneEntity(obj))
+			}
+			found[obj.DocId()] = obj
+		}
+		r.mu.Unlock()
+	}
+	objs, missing := inOrder(ids, found)
+	return objs, missing, nil
+}
+
+// load fetches id for f and caches it unless an invalidation happened since generation was read.
+func (r *CachedRepository[T, TT]) load(ctx context.Context, id string, f *flight[T], generation uint64) {
+	f.obj, f.err = r.Repository.GetByID(ctx, id)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f.err == nil && r.generation == generation {
+		r.cache.Set(id, cloneEntity(*f.obj))
+	}
+	if r.flights[id] == f {
+		delete(r.flights, id)
+	}
+	close(f.done)
+}
+
+// invalidate evicts ids and keeps loads started before the eviction from caching or sharing
+// stale entities. In a unit of work the ids are evicted again once it is committed, since loads
+// in between still see the previous state.
+func (r *CachedRepository[T, TT]) invalidate(ctx context.Context, ids ...string) {
+	if uow, ok := transactionFromContext(ctx); ok {
+		uow.onCommit(func() { r.evict(ids) })
+	}
+	r.evict(ids)
+}
+
+func (r *CachedRepository[T, TT]) evict(ids []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	for _, id := range ids {
+		r.cache.Delete(id)
+		delete(r.flights, id)
+	}
+}
+
+func (r *CachedRepository[T, TT]) Update(ctx context.Context, id string, data map[string]interface{}) error {
+	defer r.invalidate(ctx, id)
+	return r.Repository.Update(ctx, id, data)
+}
+
+func (r *CachedRepository[T, TT]) UpdateWithVersion(ctx context.Context, id string, version string, data map[string]interface{}) error {
+	defer r.invalidate(ctx, id)
+	return r.Repository.UpdateWithVersion(ctx, id, version, data)
+}
+
+func (r *CachedRepository[T, TT]) Patch(ctx context.Context, id string, patch interface{}, mask ...string) error {
+	defer r.invalidate(ctx, id)
+	return r.Repository.Patch(ctx, id, patch, mask...)
+}
+
+func (r *CachedRepository[T, TT]) Delete(ctx context.Context, id string) error {
+	defer r.invalidate(ctx, id)
+	return r.Repository.Delete(ctx, id)
+}
+
+func (r *CachedRepository[T, TT]) Restore(ctx context.Context, id string) error {
+	defer r.invalidate(ctx, id)
+	return r.Repository.Restore(ctx, id)
+}
+
+func (r *CachedRepository[T, TT]) Purge(ctx context.Context, id string) error {
+	defer r.invalidate(ctx, id)
+	return r.Repository.Purge(ctx, id)
+}
+
+func (r *CachedRepository[T, TT]) Upsert(ctx context.Context, obj T) (*string, bool, error) {
+	docID, created, err := r.Repository.Upsert(ctx, obj)
+	if docID != nil {
+		r.invalidate(ctx, *docID)
+	}
+	return docID, created, err
+}
+
+func (r *CachedRepository[T, TT]) UpdateMany(ctx context.Context, updates []BulkUpdate) ([]BulkResult, error) {
+	ids := make([]string, len(updates))
+	for i, update := range updates {
+		ids[i] = update.ID
+	}
+	defer r.invalidate(ctx, ids...)
+	return r.Repository.UpdateMany(ctx, updates)
+}
+
+func (r *CachedRepository[T, TT]) DeleteMany(ctx context.Context, ids []string) ([]BulkResult, error) {
+	defer r.invalidate(ctx, ids...)
+	return r.Repository.DeleteMany(ctx, ids)
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingRepository counts GetByID calls and blocks them until release is closed.
+type countingRepository struct {
+	Repository[*testUser, testUser]
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (r *countingRepository) GetByID(ctx context.Context, id string) (**testUser, error) {
+	r.calls.Add(1)
+	<-r.release
+	return r.Repository.GetByID(ctx, id)
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := NewLRUCache(2, 0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("a")
+	cache.Set("c", 3)
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected the least recently used entry to be evicted")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Errorf("Expected a recently used entry to be kept")
+	}
+
+	cache = NewLRUCache(10, time.Millisecond)
+	cache.Set("a", 1)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected the entry to expire")
+	}
+}
+
+func TestCachedRepository(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingRepository{
+		Repository: NewMemoryRepository[*testUser, testUser]("User"),
+		release:    make(chan struct{}),
+	}
+	repo := NewCachedRepository[*testUser, testUser](inner, NewLRUCache(10, time.Minute))
+
+	id, err := repo.Create(ctx, &testUser{Name: "A", Email: "a@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.GetByID(ctx, *id); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+	if calls := inner.calls.Load(); calls != 1 {
+		t.Errorf("Expected concurrent misses to share one load, got %d", calls)
+	}
+
+	got, err := repo.GetByID(ctx, *id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	(*got).Name = "changed"
+	got, _ = repo.GetByID(ctx, *id)
+	if (*got).Name != "A" || inner.calls.Load() != 1 {
+		t.Errorf("Expected an unmodified copy from the cache, got %+v", *got)
+	}
+	if stats := repo.Stats(); stats.Hits < 2 || stats.Hits+stats.Misses != 7 {
+		t.Errorf("Expected 7 counted calls with at least 2 hits, got %+v", stats)
+	}
+
+	if err := repo.Update(ctx, *id, map[string]interface{}{"name": "B"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = repo.GetByID(ctx, *id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*got).Name != "B" {
+		t.Errorf("Expected Update to invalidate the cache, got %q", (*got).Name)
+	}
+
+	if err := repo.Delete(ctx, *id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetByID(ctx, *id); !isNotFound(err) {
+		t.Errorf("Expected Delete to invalidate the cache, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"iter"
+
+	"cloud.google.com/go/firestore"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+// ParentAware is implemented by entities that keep the ID of the document their collection
+// belongs to, for example the customer of an order read through a collection group.
+type ParentAware interface {
+	SetParentId(id string)
+}
+
+// CollectionGroup reads the entities of one ressource from all collections of that name,
+// whatever their parent document. Page keys and "id" filters use the document path
+// relative to the database, e.g. "customers/c1/orders/o1".
+type CollectionGroup[T Entity, TT any] interface {
+	Get(ctx context.Context, opts *query.QueryOptions) (*PaginationResult[T], error)
+	All(ctx context.Context, opts *query.QueryOptions) iter.Seq2[T, error]
+}
+
+func NewFirebaseCollectionGroup[T Entity, TT any](db *firestore.Client, ressource string, opts ...Option) CollectionGroup[T, TT] {
+	o := newOptions(opts)
+	return &repository[T, TT]{
+		Db:         db,
+		Collection: o.collection(ressource),
+		Ressource:  ressource,
+		opts:       o,
+		group:      true,
+	}
+}
+
+// collectionRef returns the collection name below the repository's parent document, if it has one.
+func (r *repository[T, TT]) collectionRef(name string) *firestore.CollectionRef {
+	if r.Parent != nil {
+		return r.Parent.Collection(name)
+	}
+	return r.Db.Collection(name)
+}
+
+func (r *repository[T, TT]) collection() *firestore.CollectionRef {
+	return r.collectionRef(r.Collection)
+}
+
+func (r *repository[T, TT]) query() firestore.Query {
+	if r.group {
+		return r.Db.CollectionGroup(r.Collection).Query
+	}
+	return r.collection().Query
+}
+
+// pageDoc resolves a page key or id filter value to its document.
+func (r *repository[T, TT]) pageDoc(key string) *firestore.DocumentRef {
+	if r.group {
+		return r.Db.Doc(key)
+	}
+	return r.collection().Doc(key)
+}
+
+// pageKey is the inverse of pageDoc.
+func (r *repository[T, TT]) pageKey(ref *firestore.DocumentRef) string {
+	if r.group {
+		return relativePath(ref)
+	}
+	return ref.ID
+}
+
+// relativePath returns the path of ref below the database root.
+func relativePath(ref *firestore.DocumentRef) string {
+	path := ref.Parent.ID + "/" + ref.ID
+	if ref.Parent.Parent != nil {
+		return relativePath(ref.Parent.Parent) + "/" + path
+	}
+	return path
+}
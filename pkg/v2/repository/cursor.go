@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+// pageCursor is the decoded Next or Prev token of a page. It records the order of the query it
+// was issued for and the position of the boundary document in that order.
+type pageCursor struct {
+	OrderBy   string          `json:"o"`
+	Direction query.Direction `json:"d"`
+	ID        string          `json:"k"`
+	Value     *cursorValue    `json:"v,omitempty"`
+
+	value interface{}
+}
+
+// cursorValue is an order value with its type, so that it compares like the stored value.
+type cursorValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v,omitempty"`
+}
+
+// pageOrder returns the effective order of opts. Without an order field documents are ordered
+// by ascending ID.
+func pageOrder(opts *query.QueryOptions) (string, query.Direction) {
+	if opts.OrderBy == "" {
+		return "id", query.Asc
+	}
+	if opts.OrderByDirection == query.Desc {
+		return opts.OrderBy, query.Desc
+	}
+	return opts.OrderBy, query.Asc
+}
+
+// newPageCursor returns the cursor of the document id whose order field holds value. value is
+// ignored when ordering by ID.
+func newPageCursor(opts *query.QueryOptions, id string, value reflect.Value) (*pageCursor, error) {
+	orderBy, direction := pageOrder(opts)
+	c := &pageCursor{OrderBy: orderBy, Direction: direction, ID: id}
+	if orderBy == "id" {
+		return c, nil
+	}
+	v, err := newCursorValue(value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot paginate by %s: %w", orderBy, err)
+	}
+	c.Value = v
+	c.value, _ = v.decode()
+	return c, nil
+}
+
+func newCursorValue(value reflect.Value) (*cursorValue, error) {
+	v, ok := indirect(value)
+	if !ok {
+		return &cursorValue{Type: "null"}, nil
+	}
+	if v.Type() == timeType {
+		return &cursorValue{Type: "time", Value: v.Interface().(time.Time).Format(time.RFC3339Nano)}, nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return &cursorValue{Type: "bool", Value: strconv.FormatBool(v.Bool())}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &cursorValue{Type: "int", Value: strconv.FormatInt(v.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &cursorValue{Type: "int", Value: strconv.FormatUint(v.Uint(), 10)}, nil
+	case reflect.Float32, reflect.Float64:
+		return &cursorValue{Type: "float", Value: strconv.FormatFloat(v.Float(), 'g', -1, 64)}, nil
+	case reflect.String:
+		return &cursorValue{Type: "string", Value: v.String()}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func (v *cursorValue) decode() (interface{}, error) {
+	switch v.Type {
+	case "null":
+		return nil, nil
+	case "bool":
+		return strconv.ParseBool(v.Value)
+	case "int":
+		return strconv.ParseInt(v.Value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(v.Value, 64)
+	case "string":
+		return v.Value, nil
+	case "time":
+		return time.Parse(time.RFC3339Nano, v.Value)
+	}
+	return nil, fmt.Errorf("unknown cursor value type %s", v.Type)
+}
+
+// position compares the document id holding obj with the cursor in the query order: negative
+// if it comes before the cursor, positive if after.
+func (c *pageCursor) position(id string, obj interface{}) int {
+	cmp := 0
+	if c.OrderBy != "id" {
+		value, _ := lookupPath(reflect.ValueOf(obj), c.OrderBy)
+		cmp = compareValues(value, reflect.ValueOf(c.value))
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(id, c.ID)
+	}
+	if c.Direction == query.Desc {
+		return -cmp
+	}
+	return cmp
+}
+
+// encodeCursor returns the opaque page token of c, signed with the cursor key if one is set.
+func (o *options) encodeCursor(c *pageCursor) string {
+	data, _ := json.Marshal(c)
+	token := base64.RawURLEncoding.EncodeToString(data)
+	if o.cursorKey == nil {
+		return token
+	}
+	return token + "." + base64.RawURLEncoding.EncodeToString(o.signCursor(token))
+}
+
+func (o *options) signCursor(token string) []byte {
+	mac := hmac.New(sha256.New, o.cursorKey)
+	mac.Write([]byte(token))
+	return mac.Sum(nil)
+}
+
+// pageCursor decodes the Next or, if it is empty, the Previous token of opts. It reports whether
+// the previous page is requested and fails with ErrorBadRequest for tokens that are malformed,
+// not signed with the cursor key or issued for another order.
+func (o *options) pageCursor(ressource string, opts *query.QueryOptions) (*pageCursor, bool, error) {
+	field, token, backwards := "next", opts.Next, false
+	if token == "" {
+		field, token, backwards = "prev", opts.Previous, true
+	}
+	if token == "" {
+		return nil, false, nil
+	}
+
+	invalid := &errors.ErrorBadRequest{
+		ErrorDetail: errors.ErrorDetail{
+			Resource: ressource,
+			Field:    field,
+			Value:    token,
+			Message:  "invalid page token",
+		},
+	}
+	payload, signature, signed := strings.Cut(token, ".")
+	if o.cursorKey != nil {
+		sig, err := base64.RawURLEncoding.DecodeString(signature)
+		if !signed || err != nil || !hmac.Equal(sig, o.signCursor(payload)) {
+			return nil, false, invalid
+		}
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, false, invalid
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, false, invalid
+	}
+	if orderBy, direction := pageOrder(opts); c.OrderBy != orderBy || c.Direction != direction {
+		return nil, false, invalid
+	}
+	if c.OrderBy != "id" {
+		if c.Value == nil {
+			return nil, false, invalid
+		}
+		if c.value, err = c.Value.decode(); err != nil {
+			return nil, false, invalid
+		}
+	}
+	return &c, backwards, nil
+}
+
+// pageTokens returns the Next and Prev tokens of a page whose first and last documents have the
+// given cursors. hasNext and hasPrev tell whether documents follow or precede the page.
+func (o *options) pageTokens(first, last *pageCursor, hasNext, hasPrev bool) (string, string) {
+	var next, prev string
+	if hasNext && last != nil {
+		next = o.encodeCursor(last)
+	}
+	if hasPrev && first != nil {
+		prev = o.encodeCursor(first)
+	}
+	return next, prev
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+func TestPageCursorRoundTrip(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 30, 0, 123, time.UTC)
+	opts := &query.QueryOptions{OrderBy: "created_at", OrderByDirection: query.Desc}
+	c, err := newPageCursor(opts, "abc", reflect.ValueOf(created))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := newOptions(nil)
+	opts.Next = o.encodeCursor(c)
+	got, backwards, err := o.pageCursor("User", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backwards || got.ID != "abc" || got.value != created {
+		t.Errorf("Expected the cursor of abc at %v, got %+v", created, got)
+	}
+
+	if _, err := newPageCursor(&query.QueryOptions{OrderBy: "tags"}, "abc", reflect.ValueOf([]string{"a"})); err == nil {
+		t.Errorf("Expected an error for an order field that cannot be encoded")
+	}
+}
+
+func TestPageCursorSigned(t *testing.T) {
+	opts := &query.QueryOptions{}
+	c, err := newPageCursor(opts, "abc", reflect.Value{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := newOptions([]Option{WithCursorKey([]byte("secret"))})
+	token := signed.encodeCursor(c)
+
+	if _, _, err := signed.pageCursor("User", &query.QueryOptions{Previous: token}); err != nil {
+		t.Errorf("Expected a signed token to be accepted, got %v", err)
+	}
+	for name, forged := range map[string]string{
+		"unsigned":  newOptions(nil).encodeCursor(c),
+		"other key": newOptions([]Option{WithCursorKey([]byte("other"))}).encodeCursor(c),
+		"tampered":  "x" + token,
+	} {
+		if _, _, err := signed.pageCursor("User", &query.QueryOptions{Next: forged}); !isBadRequest(err) {
+			t.Errorf("Expected %s token to be rejected, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+)
+
+// emulatorClient connects to the Firestore emulator named by FIRESTORE_EMULATOR_HOST and skips
+// the test without one.
+func emulatorClient(t *testing.T) *firestore.Client {
+	t.Helper()
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+	client, err := firestore.NewClient(context.Background(), "test-project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// emulatorRepository returns a Firestore repository on a collection of its own.
+func emulatorRepository[T Entity, TT any](t *testing.T, ressource string, opts ...Option) *repository[T, TT] {
+	client := emulatorClient(t)
+	opts = append(opts, WithCollectionSuffix("_"+newDocID()))
+	return NewFirebaseRepository[T, TT](client, ressource, opts...).(*repository[T, TT])
+}
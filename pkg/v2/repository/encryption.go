@@ -0,0 +1,404 @@
+package repository
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+// KeyProvider supplies the AES keys of WithEncryption. Keys are 16, 24 or 32 bytes long and are
+// identified by an ID, which is stored with every encrypted value so that keys can be rotated.
+type KeyProvider interface {
+	// CurrentKey returns the ID and the key new values are encrypted with.
+	CurrentKey() (string, []byte, error)
+	// Key returns the key with the given ID.
+	Key(id string) ([]byte, error)
+	// KeyIDs returns the IDs of all keys stored values may be encrypted with, the current one
+	// included. Lookups on deterministic fields compare against the ciphertext under each of them.
+	KeyIDs() ([]string, error)
+}
+
+type staticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeyProvider returns a KeyProvider encrypting with keys[current] and decrypting with
+// any key of keys. Retired keys stay in keys until no value uses them anymore.
+func NewStaticKeyProvider(current string, keys map[string][]byte) KeyProvider {
+	return &staticKeys{current: current, keys: keys}
+}
+
+func (s *staticKeys) CurrentKey() (string, []byte, error) {
+	key, err := s.Key(s.current)
+	return s.current, key, err
+}
+
+func (s *staticKeys) Key(id string) ([]byte, error) {
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", id)
+	}
+	return key, nil
+}
+
+func (s *staticKeys) KeyIDs() ([]string, error) {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// encryptedPrefix marks encrypted values, which are stored as "enc:<key id>:<base64 nonce and ciphertext>".
+const encryptedPrefix = "enc:"
+
+// encryptedField is a string field tagged with `encrypt:"true"` or `encrypt:"deterministic"`.
+type encryptedField struct {
+	fieldInfo
+	deterministic bool
+}
+
+// encryptedFields returns the encrypted fields of t by Firestore name, or nil without WithEncryption.
+func (o *options) encryptedFields(t reflect.Type) (map[string]encryptedField, error) {
+	if o.keys == nil {
+		return nil, nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var fields map[string]encryptedField
+	for _, f := range structFields(t) {
+		mode := t.FieldByIndex(f.Index).Tag.Get("encrypt")
+		if mode == "" || mode == "false" {
+			continue
+		}
+		if mode != "true" && mode != "deterministic" {
+			return nil, fmt.Errorf("field %s of %s: unknown encrypt mode %q", f.GoName, t, mode)
+		}
+		if f.Type.Kind() != reflect.String {
+			return nil, fmt.Errorf("field %s of %s: only strings can be encrypted", f.GoName, t)
+		}
+		if fields == nil {
+			fields = map[string]encryptedField{}
+		}
+		fields[f.Name] = encryptedField{fieldInfo: f, deterministic: mode == "deterministic"}
+	}
+	return fields, nil
+}
+
+// encrypt encrypts value with the current key and the field name as additional data. Deterministic
+// fields derive the nonce from the value, so that equal values give equal ciphertexts. Empty
+// strings are kept, so that zero checks and filters on "" keep working.
+func (o *options) encrypt(f encryptedField, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	id, key, err := o.keys.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+	return o.encryptWith(f, id, key, value)
+}
+
+func (o *options) encryptWith(f encryptedField, id string, key []byte, value string) (string, error) {
+	if strings.Contains(id, ":") {
+		return "", fmt.Errorf("encryption key ID %q must not contain \":\"", id)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if f.deterministic {
+		// The nonce key is derived, so the AES key is not used for HMAC as well.
+		nonceKey := sha256.Sum256(append([]byte("nonce:"), key...))
+		mac := hmac.New(sha256.New, nonceKey[:])
+		mac.Write([]byte(f.Name + "\x00" + value))
+		copy(nonce, mac.Sum(nil))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(f.Name))
+	return encryptedPrefix + id + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// lookupValues returns the ciphertexts of value under every key of the KeyProvider, the current key
+// first, so that lookups on deterministic fields also find values written before a key rotation.
+func (o *options) lookupValues(f encryptedField, value string) ([]interface{}, error) {
+	if value == "" {
+		return []interface{}{""}, nil
+	}
+	current, _, err := o.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := o.keys.KeyIDs()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, len(ids)+1)
+	for _, id := range append([]string{current}, ids...) {
+		if id == current && len(values) > 0 {
+			continue
+		}
+		key, err := o.keys.Key(id)
+		if err != nil {
+			return nil, err
+		}
+		sealed, err := o.encryptWith(f, id, key, value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, sealed)
+	}
+	return values, nil
+}
+
+// storedValues returns the values field may be stored with for the plain value: value itself for
+// fields that are not encrypted, and its ciphertext under every key for deterministic fields, the
+// current key first. Fields encrypted with a random nonce cannot be compared and fail.
+func (o *options) storedValues(fields map[string]encryptedField, field string, value interface{}) ([]interface{}, error) {
+	f, ok := fields[field]
+	if !ok || value == nil {
+		return []interface{}{value}, nil
+	}
+	if !f.deterministic {
+		return nil, fmt.Errorf("field %s must be encrypted deterministically to be compared", field)
+	}
+	return o.lookupValues(f, fmt.Sprint(value))
+}
+
+// decrypt reverses encrypt. Values without the encrypted prefix were written before the field
+// was encrypted and are returned unchanged.
+func (o *options) decrypt(f encryptedField, value string) (string, error) {
+	payload, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+	id, data, ok := strings.Cut(payload, ":")
+	if !ok {
+		return "", fmt.Errorf("field %s: malformed encrypted value", f.Name)
+	}
+	key, err := o.keys.Key(id)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("field %s: malformed encrypted value", f.Name)
+	}
+	n := aead.NonceSize()
+	plain, err := aead.Open(nil, sealed[:n], sealed[n:], []byte(f.Name))
+	if err != nil {
+		return "", fmt.Errorf("field %s: cannot decrypt with key %q: %w", f.Name, id, err)
+	}
+	return string(plain), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealEntity returns a copy of obj with its encrypted fields encrypted, or obj itself if it has none.
+func sealEntity[T any](o *options, obj T) (T, error) {
+	fields, err := o.encryptedFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil || len(fields) == 0 {
+		return obj, err
+	}
+	sealed := cloneEntity(obj)
+	val, ok := indirect(reflect.ValueOf(sealed))
+	if !ok || val.Kind() != reflect.Struct || !val.CanAddr() {
+		return obj, nil
+	}
+	for _, f := range fields {
+		fv, err := val.FieldByIndexErr(f.Index)
+		if err != nil {
+			continue
+		}
+		value, err := o.encrypt(f, fv.String())
+		if err != nil {
+			return obj, err
+		}
+		fv.SetString(value)
+	}
+	return sealed, nil
+}
+
+// openEntity decrypts the encrypted fields of obj in place.
+func openEntity[T any](o *options, obj T) error {
+	fields, err := o.encryptedFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil || len(fields) == 0 {
+		return err
+	}
+	val, ok := indirect(reflect.ValueOf(obj))
+	if !ok || val.Kind() != reflect.Struct || !val.CanAddr() {
+		return nil
+	}
+	for _, f := range fields {
+		fv, err := val.FieldByIndexErr(f.Index)
+		if err != nil {
+			continue
+		}
+		value, err := o.decrypt(f, fv.String())
+		if err != nil {
+			return err
+		}
+		fv.SetString(value)
+	}
+	return nil
+}
+
+// sealData returns a copy of the update data with the values of encrypted fields encrypted, or
+// data itself if it sets none.
+func sealData[T any](o *options, data map[string]interface{}) (map[string]interface{}, error) {
+	fields, err := o.encryptedFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil || len(fields) == 0 {
+		return data, err
+	}
+	var sealed map[string]interface{}
+	for name, value := range data {
+		f, ok := fields[name]
+		if !ok || value == nil {
+			continue
+		}
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %s is encrypted and must be set to a string", name)
+		}
+		if sealed == nil {
+			sealed = make(map[string]interface{}, len(data))
+			for k, v := range data {
+				sealed[k] = v
+			}
+		}
+		if sealed[name], err = o.encrypt(f, s); err != nil {
+			return nil, err
+		}
+	}
+	if sealed == nil {
+		return data, nil
+	}
+	return sealed, nil
+}
+
+// uniqueFilters returns the filters matching the unique values of uniq as stored. Deterministic
+// fields match their ciphertext under any key.
+func uniqueFilters[T any](o *options, uniq map[string]interface{}) ([]query.Filter, error) {
+	fields, err := o.encryptedFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	filters := make([]query.Filter, 0, len(uniq))
+	for _, field := range sortedKeys(uniq) {
+		values, err := o.storedValues(fields, field, uniq[field])
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 1 {
+			filters = append(filters, query.Filter{Field: field, Operator: query.Eq, Value: values[0]})
+		} else {
+			filters = append(filters, query.Filter{Field: field, Operator: query.Contains, Value: values})
+		}
+	}
+	return filters, nil
+}
+
+// sealQuery returns opts with filters on deterministic fields matching the ciphertexts of their
+// values under every key, or opts itself if no filter needs it. Equality filters become "contains"
+// filters once more than one key exists. Only equality and "contains" filters work on encrypted
+// fields; others fail with ErrorBadRequest.
+func sealQuery[T any](o *options, ressource string, opts *query.QueryOptions) (*query.QueryOptions, error) {
+	fields, err := o.encryptedFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil || len(fields) == 0 || opts == nil {
+		return opts, err
+	}
+
+	var filters []query.Filter
+	for i, filter := range opts.Filters {
+		f, ok := fields[filter.Field]
+		if !ok {
+			continue
+		}
+		if !f.deterministic || (filter.Operator != query.Eq && filter.Operator != query.Eqe && filter.Operator != query.Contains) {
+			return nil, encryptedFilterError(ressource, filter, string(filter.Operator), filter.Field+" is encrypted and cannot be filtered with "+string(filter.Operator))
+		}
+		if filters == nil {
+			filters = append([]query.Filter(nil), opts.Filters...)
+		}
+		if filters[i], err = o.sealFilter(ressource, f, filter); err != nil {
+			return nil, err
+		}
+	}
+	if filters == nil {
+		return opts, nil
+	}
+	sealed := *opts
+	sealed.Filters = filters
+	return &sealed, nil
+}
+
+func (o *options) sealFilter(ressource string, f encryptedField, filter query.Filter) (query.Filter, error) {
+	var plain []interface{}
+	if filter.Operator != query.Contains {
+		if filter.Value == nil {
+			return filter, nil
+		}
+		plain = []interface{}{filter.Value}
+	} else {
+		values := reflect.ValueOf(filter.Value)
+		if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+			return filter, encryptedFilterError(ressource, filter, fmt.Sprint(filter.Value), "filter "+filter.Field+" requires a list value")
+		}
+		for i := 0; i < values.Len(); i++ {
+			plain = append(plain, values.Index(i).Interface())
+		}
+	}
+
+	sealed := make([]interface{}, 0, len(plain))
+	for _, value := range plain {
+		values, err := o.lookupValues(f, fmt.Sprint(value))
+		if err != nil {
+			return filter, err
+		}
+		sealed = append(sealed, values...)
+	}
+	if filter.Operator != query.Contains && len(sealed) == 1 {
+		filter.Value = sealed[0]
+		return filter, nil
+	}
+	filter.Operator = query.Contains
+	filter.Value = sealed
+	return filter, nil
+}
+
+func encryptedFilterError(ressource string, filter query.Filter, value, message string) error {
+	return &errors.ErrorBadRequest{
+		ErrorDetail: errors.ErrorDetail{
+			Resource: ressource,
+			Field:    filter.Field,
+			Value:    value,
+			Message:  message,
+		},
+	}
+}
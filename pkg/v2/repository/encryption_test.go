@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+type testPatient struct {
+	testUser
+	Insurance string `firestore:"insurance" encrypt:"deterministic"`
+	IBAN      string `firestore:"iban" db:"iban_cipher" encrypt:"true"`
+}
+
+func (p *testPatient) UniqFields() map[string]interface{} {
+	return map[string]interface{}{"insurance": p.Insurance}
+}
+
+func testKeys(current string) KeyProvider {
+	return NewStaticKeyProvider(current, map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	})
+}
+
+func TestEncryptEntity(t *testing.T) {
+	o := newOptions([]Option{WithEncryption(testKeys("k1"))})
+	patient := &testPatient{testUser: testUser{Name: "Ada"}, Insurance: "A123456789", IBAN: "DE02120300000000202051"}
+
+	stored, err := sealEntity(o, patient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patient.Insurance != "A123456789" || patient.IBAN != "DE02120300000000202051" {
+		t.Errorf("Expected the entity to stay in plain text, got %+v", patient)
+	}
+	if stored.Name != "Ada" || !strings.HasPrefix(stored.Insurance, "enc:k1:") || !strings.HasPrefix(stored.IBAN, "enc:k1:") {
+		t.Errorf("Expected the tagged fields to be encrypted with k1, got %+v", stored)
+	}
+
+	again, err := sealEntity(o, patient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Insurance != stored.Insurance {
+		t.Errorf("Expected deterministic fields to encrypt to the same value")
+	}
+	if again.IBAN == stored.IBAN {
+		t.Errorf("Expected random nonces for other encrypted fields")
+	}
+
+	// Values written with a retired key are still decrypted.
+	rotated := newOptions([]Option{WithEncryption(testKeys("k2"))})
+	if err := openEntity(rotated, stored); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stored, patient) {
+		t.Errorf("Expected %+v after decryption, got %+v", patient, stored)
+	}
+
+	legacy := &testPatient{Insurance: "P111111111"}
+	if err := openEntity(o, legacy); err != nil || legacy.Insurance != "P111111111" {
+		t.Errorf("Expected values without prefix to be kept, got %q, %v", legacy.Insurance, err)
+	}
+
+	sealed, err := sealEntity(o, patient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := newOptions([]Option{WithEncryption(NewStaticKeyProvider("k3", map[string][]byte{"k3": bytes.Repeat([]byte{3}, 32)}))})
+	if err := openEntity(unknown, sealed); err == nil {
+		t.Error("Expected an error for an unknown key")
+	}
+}
+
+func TestSealData(t *testing.T) {
+	o := newOptions([]Option{WithEncryption(testKeys("k1"))})
+	data := map[string]interface{}{"name": "Ada", "iban": "DE02120300000000202051"}
+
+	sealed, err := sealData[*testPatient](o, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["iban"] != "DE02120300000000202051" {
+		t.Errorf("Expected data to be left unchanged, got %v", data)
+	}
+	if sealed["name"] != "Ada" || !strings.HasPrefix(sealed["iban"].(string), encryptedPrefix) {
+		t.Errorf("Expected only iban to be encrypted, got %v", sealed)
+	}
+	if _, err := sealData[*testPatient](o, map[string]interface{}{"iban": 42}); err == nil {
+		t.Error("Expected an error for a value that is not a string")
+	}
+
+	if _, err := uniqueFilters[*testPatient](o, map[string]interface{}{"iban": "x"}); err == nil {
+		t.Error("Expected an error for a unique field with random nonces")
+	}
+}
+
+func TestSealQuery(t *testing.T) {
+	o := newOptions([]Option{WithEncryption(testKeys("k1"))})
+	stored, err := sealEntity(o, &testPatient{Insurance: "A123456789"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &query.QueryOptions{Filters: []query.Filter{
+		{Field: "name", Operator: query.Eq, Value: "Ada"},
+		{Field: "insurance", Operator: query.Eq, Value: "A123456789"},
+	}}
+	sealed, err := sealQuery[*testPatient](o, "Patient", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Filters[1].Value != "A123456789" {
+		t.Errorf("Expected opts to be left unchanged, got %v", opts.Filters)
+	}
+	if sealed.Filters[0] != opts.Filters[0] || sealed.Filters[1].Operator != query.Contains || !slices.Contains(sealed.Filters[1].Value.([]interface{}), interface{}(stored.Insurance)) {
+		t.Errorf("Expected the insurance filter to match the stored value, got %v", sealed.Filters)
+	}
+
+	single := newOptions([]Option{WithEncryption(NewStaticKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}))})
+	eq, err := sealQuery[*testPatient](single, "Patient", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eq.Filters[1].Operator != query.Eq || eq.Filters[1].Value != stored.Insurance {
+		t.Errorf("Expected an equality filter with a single key, got %v", eq.Filters[1])
+	}
+
+	in, err := sealQuery[*testPatient](o, "Patient", &query.QueryOptions{Filters: []query.Filter{
+		{Field: "insurance", Operator: query.Contains, Value: []string{"A123456789", "B987654321"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values := in.Filters[0].Value.([]interface{}); len(values) != 4 || values[0] != stored.Insurance {
+		t.Errorf("Expected every contains value to be encrypted under both keys, got %v", in.Filters[0].Value)
+	}
+
+	for _, filter := range []query.Filter{
+		{Field: "iban", Operator: query.Eq, Value: "DE02120300000000202051"},
+		{Field: "insurance", Operator: query.Gt, Value: "a"},
+		{Field: "insurance", Operator: query.Contains, Value: "A123456789"},
+	} {
+		_, err := sealQuery[*testPatient](o, "Patient", &query.QueryOptions{Filters: []query.Filter{filter}})
+		if !isBadRequest(err) {
+			t.Errorf("Expected ErrorBadRequest for %v, got %v", filter, err)
+		}
+	}
+
+	plain := &query.QueryOptions{Filters: opts.Filters}
+	if got, err := sealQuery[*testPatient](newOptions(nil), "Patient", plain); err != nil || got != plain {
+		t.Errorf("Expected opts without WithEncryption, got %v, %v", got, err)
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	old := newOptions([]Option{WithEncryption(testKeys("k1"))})
+	stored, err := sealEntity(old, &testPatient{Insurance: "A123456789"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newOptions([]Option{WithEncryption(testKeys("k2"))})
+	opts, err := sealQuery[*testPatient](rotated, "Patient", &query.QueryOptions{Filters: []query.Filter{
+		{Field: "insurance", Operator: query.Eq, Value: "A123456789"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := opts.Filters[0].Value.([]interface{})
+	if !slices.Contains(values, interface{}(stored.Insurance)) {
+		t.Errorf("Expected the filter to find the value written with k1, got %v", values)
+	}
+	if !strings.HasPrefix(values[0].(string), "enc:k2:") {
+		t.Errorf("Expected the current key first, got %v", values)
+	}
+
+	filters, err := uniqueFilters[*testPatient](rotated, map[string]interface{}{"insurance": "A123456789", "name": "Ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters[0].Field != "insurance" || filters[0].Operator != query.Contains || !slices.Contains(filters[0].Value.([]interface{}), interface{}(stored.Insurance)) {
+		t.Errorf("Expected the unique check to find the value written with k1, got %v", filters)
+	}
+	if filters[1] != (query.Filter{Field: "name", Operator: query.Eq, Value: "Ada"}) {
+		t.Errorf("Expected plain fields to be compared as is, got %v", filters[1])
+	}
+}
+
+func TestEncryptionKeyRotationEmulator(t *testing.T) {
+	ctx := context.Background()
+	for _, opts := range [][]Option{nil, {WithUniqueConstraints()}} {
+		old := emulatorRepository[*testPatient, testPatient](t, "Patient", append(opts, WithEncryption(testKeys("k1")))...)
+		id, err := old.Create(ctx, &testPatient{testUser: testUser{Name: "Ada"}, Insurance: "A123456789"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rotated := NewFirebaseRepository[*testPatient, testPatient](old.Db, "Patient",
+			append(opts, WithEncryption(testKeys("k2")), WithCollectionSuffix(old.opts.collectionSuffix))...)
+
+		page, err := rotated.Get(ctx, &query.QueryOptions{Filters: []query.Filter{{Field: "insurance", Operator: query.Eq, Value: "A123456789"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) != 1 || page.Items[0].Name != "Ada" {
+			t.Errorf("Expected to find the value written with k1, got %+v", page.Items)
+		}
+
+		_, err = rotated.Create(ctx, &testPatient{testUser: testUser{Name: "Bob"}, Insurance: "A123456789"})
+		if _, ok := err.(*errors.ErrorAlreadyExists); !ok {
+			t.Errorf("Expected ErrorAlreadyExists for the value written with k1, got %v", err)
+		}
+
+		// Changing the value releases what was claimed under k1.
+		if err := rotated.Patch(ctx, *id, &testPatient{Insurance: "A000000000"}, "Insurance"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rotated.Create(ctx, &testPatient{testUser: testUser{Name: "Bob"}, Insurance: "A123456789"}); err != nil {
+			t.Errorf("Expected the old value to be free again, got %v", err)
+		}
+	}
+}
+
+type testSqlRecorder struct {
+	stmt string
+	args []interface{}
+}
+
+func (r *testSqlRecorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.stmt, r.args = query, args
+	return driver.RowsAffected(1), nil
+}
+
+func TestSqlEncryptionColumnNames(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSqlRepository[*testPatient, testPatient](nil, "Patient", SqliteDialect, WithEncryption(testKeys("k1"))).(*sqlRepository[*testPatient, testPatient])
+
+	var db testSqlRecorder
+	if _, err := repo.exec(ctx, &db, "id1", map[string]interface{}{"iban_cipher": "DE02120300000000202051"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if db.stmt != `UPDATE "patients" SET "iban_cipher" = ? WHERE "id" = ?` {
+		t.Errorf("Unexpected statement %s", db.stmt)
+	}
+	if value, _ := db.args[0].(string); !strings.HasPrefix(value, "enc:k1:") {
+		t.Errorf("Expected the value set by column name to be encrypted, got %v", db.args[0])
+	}
+
+	_, err := repo.Get(ctx, &query.QueryOptions{Filters: []query.Filter{{Field: "iban_cipher", Operator: query.Eq, Value: "DE02120300000000202051"}}})
+	if !isBadRequest(err) {
+		t.Errorf("Expected ErrorBadRequest for a filter on the column of an encrypted field, got %v", err)
+	}
+}
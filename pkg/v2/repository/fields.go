@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// fieldInfo describes a persisted struct field and its Firestore name.
+type fieldInfo struct {
+	Name   string
+	GoName string
+	Index  []int
+	Type   reflect.Type
+}
+
+// firestoreName returns the Firestore name of a struct field and whether the field is persisted.
+func firestoreName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("firestore"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, true
+}
+
+// structFields lists the persisted fields of a struct type, flattening untagged embedded structs like Firestore does.
+func structFields(t reflect.Type) []fieldInfo {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("firestore") == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, inner := range structFields(ft) {
+					inner.Index = append([]int{i}, inner.Index...)
+					fields = append(fields, inner)
+				}
+				continue
+			}
+		}
+		name, ok := firestoreName(f)
+		if !ok {
+			continue
+		}
+		fields = append(fields, fieldInfo{
+			Name:   name,
+			GoName: f.Name,
+			Index:  []int{i},
+			Type:   f.Type,
+		})
+	}
+	return fields
+}
+
+// indirect dereferences pointers and interfaces, returning false for nil values.
+func indirect(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	return v, v.IsValid()
+}
+
+// lookupPath resolves a dotted Firestore path on a value.
+func lookupPath(v reflect.Value, path string) (reflect.Value, bool) {
+	for _, part := range strings.Split(path, ".") {
+		var ok bool
+		if v, ok = indirect(v); !ok {
+			return reflect.Value{}, false
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			found := false
+			for _, f := range structFields(v.Type()) {
+				if f.Name == part {
+					fv, err := v.FieldByIndexErr(f.Index)
+					if err != nil {
+						return reflect.Value{}, false
+					}
+					v, found = fv, true
+					break
+				}
+			}
+			if !found {
+				return reflect.Value{}, false
+			}
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, false
+			}
+			mv := v.MapIndex(reflect.ValueOf(part).Convert(v.Type().Key()))
+			if !mv.IsValid() {
+				return reflect.Value{}, false
+			}
+			v = mv
+		default:
+			return reflect.Value{}, false
+		}
+	}
+	return v, true
+}
+
+// setPath assigns value to the dotted Firestore path on an addressable value.
+func setPath(v reflect.Value, path string, value interface{}) error {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		last := i == len(parts)-1
+		switch v.Kind() {
+		case reflect.Struct:
+			found := false
+			for _, f := range structFields(v.Type()) {
+				if f.Name != part {
+					continue
+				}
+				fv := v
+				for _, idx := range f.Index {
+					if fv.Kind() == reflect.Pointer {
+						if fv.IsNil() {
+							fv.Set(reflect.New(fv.Type().Elem()))
+						}
+						fv = fv.Elem()
+					}
+					fv = fv.Field(idx)
+				}
+				v, found = fv, true
+				break
+			}
+			if !found {
+				return fmt.Errorf("unknown field %s", path)
+			}
+			if last {
+				return assignValue(v, value)
+			}
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return fmt.Errorf("unsupported map key for field %s", path)
+			}
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+			key := reflect.ValueOf(part).Convert(v.Type().Key())
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if existing := v.MapIndex(key); existing.IsValid() {
+				elem.Set(existing)
+			}
+			if last {
+				if value == firestore.Delete {
+					v.SetMapIndex(key, reflect.Value{})
+					return nil
+				}
+				if err := assignValue(elem, value); err != nil {
+					return err
+				}
+			} else if err := setPath(elem, strings.Join(parts[i+1:], "."), value); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+			return nil
+		default:
+			return fmt.Errorf("cannot set field %s", path)
+		}
+	}
+	return nil
+}
+
+// assignValue stores value in dst, converting between compatible types.
+func assignValue(dst reflect.Value, value interface{}) error {
+	if value == nil || value == firestore.Delete {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if value == firestore.ServerTimestamp {
+		value = time.Now()
+	}
+	src := reflect.ValueOf(value)
+	switch {
+	case src.Type().AssignableTo(dst.Type()):
+		dst.Set(src)
+	case dst.Kind() == reflect.Pointer && src.Type().AssignableTo(dst.Type().Elem()):
+		p := reflect.New(dst.Type().Elem())
+		p.Elem().Set(src)
+		dst.Set(p)
+	case src.Kind() == reflect.Pointer && !src.IsNil() && src.Elem().Type().AssignableTo(dst.Type()):
+		dst.Set(src.Elem())
+	case src.Type().ConvertibleTo(dst.Type()) && src.Kind() != reflect.String && dst.Kind() != reflect.String:
+		dst.Set(src.Convert(dst.Type()))
+	case src.Kind() == reflect.String && dst.Kind() == reflect.String:
+		dst.SetString(src.String())
+	default:
+		return fmt.Errorf("cannot assign %s to %s", src.Type(), dst.Type())
+	}
+	return nil
+}
+
+// setTimestamps sets the named time.Time fields of a struct pointer to now.
+func setTimestamps(obj interface{}, fieldNames ...string) {
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Pointer || val.IsNil() {
+		return
+	}
+	val = val.Elem()
+	if val.Kind() != reflect.Struct {
+		return
+	}
+	now := time.Now()
+	for _, fieldName := range fieldNames {
+		field := val.FieldByName(fieldName)
+		if field.IsValid() && field.CanSet() && field.Type() == timeType {
+			field.Set(reflect.ValueOf(now))
+		}
+	}
+}
+
+// newEntity allocates a T, following one level of pointer so *Struct entities are usable.
+func newEntity[T any]() T {
+	var obj T
+	if t := reflect.TypeOf((*T)(nil)).Elem(); t.Kind() == reflect.Pointer {
+		obj = reflect.New(t.Elem()).Interface().(T)
+	}
+	return obj
+}
@@ -0,0 +1,75 @@
+package repository
+
+import "context"
+
+// BeforeCreator is called before a new entity is checked for uniqueness and written.
+// It can normalize or derive fields; an error aborts the create.
+//
+// Like all lifecycle hooks it runs inside the repository's transactions where the store has
+// them, may be called again when a transaction is retried and must not call the same repository.
+type BeforeCreator interface {
+	BeforeCreate(ctx context.Context) error
+}
+
+// AfterCreator is called once the entity has its document ID, inside the create transaction
+// where the store has one. An error rolls the create back there.
+type AfterCreator interface {
+	AfterCreate(ctx context.Context) error
+}
+
+// BeforeUpdater is called on the stored entity before changes are applied. The hook may
+// modify changes, for example to normalize values or add derived fields; an error aborts the update.
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context, changes map[string]interface{}) error
+}
+
+// BeforeDeleter is called on the stored entity before it is deleted; an error vetoes the delete.
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+// AfterLoader is called on every entity read from the store.
+type AfterLoader interface {
+	AfterLoad(ctx context.Context) error
+}
+
+func beforeCreate(ctx context.Context, obj interface{}) error {
+	if h, ok := obj.(BeforeCreator); ok {
+		return h.BeforeCreate(ctx)
+	}
+	return nil
+}
+
+func afterCreate(ctx context.Context, obj interface{}) error {
+	if h, ok := obj.(AfterCreator); ok {
+		return h.AfterCreate(ctx)
+	}
+	return nil
+}
+
+func beforeUpdate(ctx context.Context, obj interface{}, changes map[string]interface{}) error {
+	if h, ok := obj.(BeforeUpdater); ok {
+		return h.BeforeUpdate(ctx, changes)
+	}
+	return nil
+}
+
+func beforeDelete(ctx context.Context, obj interface{}) error {
+	if h, ok := obj.(BeforeDeleter); ok {
+		return h.BeforeDelete(ctx)
+	}
+	return nil
+}
+
+func afterLoad(ctx context.Context, obj interface{}) error {
+	if h, ok := obj.(AfterLoader); ok {
+		return h.AfterLoad(ctx)
+	}
+	return nil
+}
+
+// implements reports whether the entity type T implements the hook interface H.
+func implements[H any, T any]() bool {
+	_, ok := any(newEntity[T]()).(H)
+	return ok
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"strings"
+	"testing"
+)
+
+var errLocked = stderrors.New("locked")
+
+type testHookedUser struct {
+	testUser
+	Locked  bool   `firestore:"locked"`
+	Created string `firestore:"-"`
+	Loaded  bool   `firestore:"-"`
+}
+
+func (u *testHookedUser) BeforeCreate(ctx context.Context) error {
+	u.Email = strings.ToLower(u.Email)
+	return nil
+}
+
+func (u *testHookedUser) AfterCreate(ctx context.Context) error {
+	u.Created = u.ID
+	return nil
+}
+
+func (u *testHookedUser) BeforeUpdate(ctx context.Context, changes map[string]interface{}) error {
+	if u.Locked {
+		return errLocked
+	}
+	if email, ok := changes["email"].(string); ok {
+		changes["email"] = strings.ToLower(email)
+	}
+	return nil
+}
+
+func (u *testHookedUser) BeforeDelete(ctx context.Context) error {
+	if u.Locked {
+		return errLocked
+	}
+	return nil
+}
+
+func (u *testHookedUser) AfterLoad(ctx context.Context) error {
+	u.Loaded = true
+	return nil
+}
+
+func TestMemoryRepositoryHooks(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testHookedUser, testHookedUser]("User")
+
+	user := &testHookedUser{testUser: testUser{Email: "A@Example.com"}}
+	id, err := repo.Create(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Created != *id {
+		t.Errorf("Expected AfterCreate to see the document ID %s, got %q", *id, user.Created)
+	}
+	if _, err := repo.Create(ctx, &testHookedUser{testUser: testUser{Email: "a@example.com"}}); err == nil {
+		t.Errorf("Expected BeforeCreate to normalize before the uniqueness check")
+	}
+
+	data := map[string]interface{}{"email": "B@Example.com"}
+	if err := repo.Update(ctx, *id, data); err != nil {
+		t.Fatal(err)
+	}
+	if data["email"] != "B@Example.com" {
+		t.Errorf("Expected BeforeUpdate not to modify the caller's map, got %v", data["email"])
+	}
+	got, err := repo.GetByID(ctx, *id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*got).Email != "b@example.com" || !(*got).Loaded {
+		t.Errorf("Expected normalized email and AfterLoad to run, got %+v", *got)
+	}
+
+	if err := repo.Update(ctx, *id, map[string]interface{}{"locked": true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Update(ctx, *id, map[string]interface{}{"name": "B"}); !stderrors.Is(err, errLocked) {
+		t.Errorf("Expected BeforeUpdate to veto the update, got %v", err)
+	}
+	if err := repo.Delete(ctx, *id); !stderrors.Is(err, errLocked) {
+		t.Errorf("Expected BeforeDelete to veto the delete, got %v", err)
+	}
+	if _, err := repo.GetByID(ctx, *id); err != nil {
+		t.Errorf("Expected vetoed delete to keep the document, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"iter"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+const defaultPageSize = 100
+
+// allPages walks every page returned by get, fetching the next page only once the consumer
+// has processed the current one. The caller's options are not modified.
+func allPages[T any](ctx context.Context, get func(ctx context.Context, opts *query.QueryOptions) (*PaginationResult[T], error), opts *query.QueryOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		page := query.QueryOptions{Limit: defaultPageSize}
+		if opts != nil {
+			page = *opts
+			page.Previous = ""
+			if page.Limit <= 0 {
+				page.Limit = defaultPageSize
+			}
+		}
+
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+			res, err := get(ctx, &page)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, item := range res.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if res.Next == "" || len(res.Items) == 0 {
+				return
+			}
+			page.Next = res.Next
+		}
+	}
+}
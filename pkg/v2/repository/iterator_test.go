@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+func TestMemoryRepositoryAll(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User")
+	seedUsers(t, repo,
+		&testUser{Email: "1", Age: 1},
+		&testUser{Email: "2", Age: 2},
+		&testUser{Email: "3", Age: 3},
+		&testUser{Email: "4", Age: 4},
+		&testUser{Email: "5", Age: 5},
+	)
+
+	opts := &query.QueryOptions{
+		Limit:   2,
+		OrderBy: "age",
+		Filters: []query.Filter{{Field: "age", Operator: query.Gt, Value: 1}},
+	}
+	var ages []int
+	for user, err := range repo.All(ctx, opts) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ages = append(ages, user.Age)
+	}
+	if len(ages) != 4 || ages[0] != 2 || ages[3] != 5 {
+		t.Errorf("Expected ages 2..5, got %v", ages)
+	}
+	if opts.Next != "" {
+		t.Errorf("Expected caller options to stay untouched, got next %s", opts.Next)
+	}
+
+	count := 0
+	for range repo.All(ctx, opts) {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	if count != 3 {
+		t.Errorf("Expected iteration to stop after break, got %d", count)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	for _, err := range repo.All(cancelled, nil) {
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	}
+}
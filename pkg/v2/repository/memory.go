@@ -0,0 +1,930 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"iter"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+type memoryRepository[T Entity, TT any] struct {
+	mu        sync.RWMutex
+	docs      map[string]T
+	deleted   map[string]time.Time
+	revisions map[string]int64
+	changed   chan struct{}
+	history   *memoryRepository[*AuditRecord, AuditRecord]
+	Ressource string
+	opts      *options
+}
+
+// NewMemoryRepository creates an in-memory Repository that follows the query semantics of the Firestore repository.
+// It is intended for unit tests that must run without Firestore.
+func NewMemoryRepository[T Entity, TT any](ressource string, opts ...Option) Repository[T, TT] {
+	r := &memoryRepository[T, TT]{
+		docs:      make(map[string]T),
+		deleted:   make(map[string]time.Time),
+		revisions: make(map[string]int64),
+		changed:   make(chan struct{}),
+		Ressource: ressource,
+		opts:      newOptions(opts),
+	}
+	if r.opts.audit {
+		r.history = NewMemoryRepository[*AuditRecord, AuditRecord](ressource + "History").(*memoryRepository[*AuditRecord, AuditRecord])
+	}
+	return r
+}
+
+func (r *memoryRepository[T, TT]) configuration() *options {
+	return r.opts
+}
+
+func (r *memoryRepository[T, TT]) GetClient() *firestore.Client {
+	return nil
+}
+
+type memoryDoc[T any] struct {
+	id  string
+	obj T
+}
+
+func (r *memoryRepository[T, TT]) Get(ctx context.Context, opts *query.QueryOptions) (*PaginationResult[T], error) {
+	if opts == nil {
+		opts = &query.QueryOptions{
+			Limit: 100,
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cursor, backwards, err := r.opts.pageCursor(r.Ressource, opts)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := projection[T](r.Ressource, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := r.matching(opts)
+	if cursor != nil {
+		docs = filterDocs(docs, func(doc memoryDoc[T]) bool {
+			if backwards {
+				return cursor.position(doc.id, doc.obj) < 0
+			}
+			return cursor.position(doc.id, doc.obj) > 0
+		})
+	}
+	more := opts.Limit > 0 && len(docs) > opts.Limit
+	if more && backwards {
+		docs = docs[len(docs)-opts.Limit:]
+	} else if more {
+		docs = docs[:opts.Limit]
+	}
+	hasNext, hasPrev := more, cursor != nil
+	if backwards {
+		hasNext, hasPrev = true, more
+	}
+
+	var first, last *pageCursor
+	if len(docs) > 0 {
+		if first, err = docCursor(opts, docs[0]); err != nil {
+			return nil, err
+		}
+		if last, err = docCursor(opts, docs[len(docs)-1]); err != nil {
+			return nil, err
+		}
+	}
+	nextPageKey, prevPageKey := r.opts.pageTokens(first, last, hasNext, hasPrev)
+
+	objs := make([]T, 0, len(docs))
+	for _, doc := range docs {
+		stored := doc.obj
+		if paths != nil {
+			stored = project(stored, paths)
+		}
+		obj, err := r.load(ctx, doc.id, stored)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+
+	var count *int64
+	if opts.IncludeTotal {
+		n := int64(len(r.matching(opts)))
+		count = &n
+	}
+
+	return &PaginationResult[T]{
+		Items:   objs,
+		Limit:   opts.Limit,
+		Next:    nextPageKey,
+		Prev:    prevPageKey,
+		Filters: &opts.Filters,
+		Total:   count,
+		HasNext: nextPageKey != "",
+		HasPrev: prevPageKey != "",
+	}, nil
+}
+
+func docCursor[T any](opts *query.QueryOptions, doc memoryDoc[T]) (*pageCursor, error) {
+	var value reflect.Value
+	if orderBy, _ := pageOrder(opts); orderBy != "id" {
+		value, _ = lookupPath(reflect.ValueOf(doc.obj), orderBy)
+	}
+	return newPageCursor(opts, doc.id, value)
+}
+
+func (r *memoryRepository[T, TT]) Count(ctx context.Context, opts *query.QueryOptions) (int64, error) {
+	if opts == nil {
+		opts = &query.QueryOptions{}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int64(len(r.matching(opts))), nil
+}
+
+func (r *memoryRepository[T, TT]) Aggregate(ctx context.Context, opts *query.QueryOptions, agg Aggregation) (*AggregationResult, error) {
+	if opts == nil {
+		opts = &query.QueryOptions{}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	docs := r.matching(opts)
+	objs := make([]T, len(docs))
+	for i, doc := range docs {
+		objs[i] = doc.obj
+	}
+	return aggregate(objs, agg), nil
+}
+
+// matching returns the stored documents matching the filters of opts in its order.
+func (r *memoryRepository[T, TT]) matching(opts *query.QueryOptions) []memoryDoc[T] {
+	docs := make([]memoryDoc[T], 0, len(r.docs))
+	for id, obj := range r.docs {
+		if r.isDeleted(id) && !opts.IncludeDeleted {
+			continue
+		}
+		if !matchesFilters(id, obj, opts.Filters) {
+			continue
+		}
+		if opts.OrderBy != "" && opts.OrderBy != "id" {
+			if _, ok := lookupPath(reflect.ValueOf(obj), opts.OrderBy); !ok {
+				continue
+			}
+		}
+		docs = append(docs, memoryDoc[T]{id: id, obj: obj})
+	}
+	less := docLess[T](opts.OrderBy, opts.OrderByDirection)
+	sort.SliceStable(docs, func(i, j int) bool {
+		return less(docs[i], docs[j])
+	})
+	return docs
+}
+
+// All iterates over every document matching opts, fetching pages of opts.Limit lazily.
+// Iteration stops at the first error, which is yielded with a zero value.
+func (r *memoryRepository[T, TT]) All(ctx context.Context, opts *query.QueryOptions) iter.Seq2[T, error] {
+	return allPages(ctx, r.Get, opts)
+}
+
+func (r *memoryRepository[T, TT]) GetByID(ctx context.Context, id string) (*T, error) {
+	if id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.docs[id]
+	if !ok || r.isDeleted(id) {
+		return nil, r.notFound(id)
+	}
+	obj, err := r.load(ctx, id, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	return &obj, nil
+}
+
+func (r *memoryRepository[T, TT]) GetByIDs(ctx context.Context, ids []string) ([]T, []string, error) {
+	ids, err := uniqueIDs(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := make(map[string]T, len(ids))
+	for _, id := range ids {
+		stored, ok := r.docs[id]
+		if !ok || r.isDeleted(id) {
+			continue
+		}
+		obj, err := r.load(ctx, id, stored)
+		if err != nil {
+			return nil, nil, err
+		}
+		found[id] = obj
+	}
+	objs, missing := inOrder(ids, found)
+	return objs, missing, nil
+}
+
+// load returns a copy of a stored entity with its document ID and version applied and runs AfterLoad.
+func (r *memoryRepository[T, TT]) load(ctx context.Context, id string, stored T) (T, error) {
+	obj := cloneEntity(stored)
+	obj.SetDocId(id)
+	setDocVersion(obj, r.version(id, obj))
+	return obj, afterLoad(ctx, obj)
+}
+
+// version is the integer Version field if present, otherwise a per document revision counter.
+func (r *memoryRepository[T, TT]) version(id string, obj T) string {
+	if version, ok := entityVersion(obj); ok {
+		return strconv.FormatInt(version, 10)
+	}
+	return strconv.FormatInt(r.revisions[id], 10)
+}
+
+func (r *memoryRepository[T, TT]) CreateQueryNotExists(ctx context.Context, obj T, funcQuery func(firestore.Query) firestore.Query) (*string, error) {
+	return nil, fmt.Errorf("CreateQueryNotExists: %w", ErrNotSupported)
+}
+
+func (r *memoryRepository[T, TT]) Create(ctx context.Context, obj T) (*string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := beforeCreate(ctx, obj); err != nil {
+		return nil, err
+	}
+	if err := r.opts.validate(obj); err != nil {
+		return nil, err
+	}
+	if err := r.checkUnique("", obj.UniqFields()); err != nil {
+		return nil, err
+	}
+
+	return r.insert(ctx, obj)
+}
+
+func (r *memoryRepository[T, TT]) Upsert(ctx context.Context, obj T) (*string, bool, error) {
+	uniq := obj.UniqFields()
+	if len(uniq) == 0 {
+		return nil, false, errUniqFieldsRequired
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matches []string
+	for id, stored := range r.docs {
+		if r.opts.excludesDeletedFromUnique() && r.isDeleted(id) {
+			continue
+		}
+		if matchesAll(stored, uniq) {
+			matches = append(matches, id)
+		}
+	}
+	if len(matches) == 0 {
+		if err := beforeCreate(ctx, obj); err != nil {
+			return nil, false, err
+		}
+		if err := r.opts.validate(obj); err != nil {
+			return nil, false, err
+		}
+		if err := r.checkUnique("", uniq); err != nil {
+			return nil, false, err
+		}
+		docID, err := r.insert(ctx, obj)
+		return docID, err == nil, err
+	}
+	id := matches[0]
+	if len(matches) > 1 || r.isDeleted(id) {
+		return nil, false, alreadyExists(r.Ressource, uniq)
+	}
+
+	prepareUpsert(obj, r.docs[id])
+	if err := r.opts.validate(obj); err != nil {
+		return nil, false, err
+	}
+	if err := r.apply(ctx, id, r.docs[id], r.opts.upsertData(obj)); err != nil {
+		return nil, false, err
+	}
+	obj.SetDocId(id)
+	return &id, false, nil
+}
+
+// checkUnique fails if a document other than id holds all the given values.
+func (r *memoryRepository[T, TT]) checkUnique(id string, uniq map[string]interface{}) error {
+	for _, group := range r.opts.uniqueGroups(uniq) {
+		for storedID, stored := range r.docs {
+			if storedID == id || (r.opts.excludesDeletedFromUnique() && r.isDeleted(storedID)) {
+				continue
+			}
+			if matchesAll(stored, group) {
+				return alreadyExists(r.Ressource, group)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository[T, TT]) CreateEasy(ctx context.Context, obj T) (*string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := beforeCreate(ctx, obj); err != nil {
+		return nil, err
+	}
+	if err := r.opts.validate(obj); err != nil {
+		return nil, err
+	}
+	return r.insert(ctx, obj)
+}
+
+// insert stores obj under a new ID and runs AfterCreate, removing the document again if the hook fails.
+func (r *memoryRepository[T, TT]) insert(ctx context.Context, obj T) (*string, error) {
+	prepareCreate(obj)
+	docID := newDocID()
+	r.docs[docID] = cloneEntity(obj)
+	r.revisions[docID] = 1
+	obj.SetDocId(docID)
+	if err := afterCreate(ctx, obj); err != nil {
+		r.purge(docID)
+		return nil, err
+	}
+	r.audit(ctx, docID, AuditCreate, createdChanges(obj))
+	r.notify()
+	return &docID, nil
+}
+
+func (r *memoryRepository[T, TT]) Update(ctx context.Context, id string, data map[string]interface{}) error {
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.docs[id]
+	if !ok {
+		return r.notFound(id)
+	}
+	return r.apply(ctx, id, stored, data)
+}
+
+// Patch updates the masked fields of patch, see patchData.
+func (r *memoryRepository[T, TT]) Patch(ctx context.Context, id string, patch interface{}, mask ...string) error {
+	data, err := patchData(r.Ressource, reflect.TypeOf((*T)(nil)).Elem(), patch, mask)
+	if err != nil {
+		return err
+	}
+	if err := r.opts.validatePatch(patch, mask); err != nil {
+		return err
+	}
+	return r.Update(ctx, id, data)
+}
+
+func (r *memoryRepository[T, TT]) UpdateWithVersion(ctx context.Context, id string, version string, data map[string]interface{}) error {
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.docs[id]
+	if !ok {
+		return r.notFound(id)
+	}
+	if r.version(id, stored) != version {
+		return preconditionFailed(r.Ressource, id, version)
+	}
+	return r.apply(ctx, id, stored, data)
+}
+
+// apply runs BeforeUpdate, writes data to a copy of the stored entity and bumps its version.
+func (r *memoryRepository[T, TT]) apply(ctx context.Context, id string, stored T, data map[string]interface{}) error {
+	if implements[BeforeUpdater, T]() {
+		current, err := r.load(ctx, id, stored)
+		if err != nil {
+			return err
+		}
+		data = maps.Clone(data)
+		if err := beforeUpdate(ctx, current, data); err != nil {
+			return err
+		}
+	}
+
+	obj := cloneEntity(stored)
+	val := reflect.ValueOf(&obj).Elem()
+	for path, value := range data {
+		if err := setPath(val, path, value); err != nil {
+			return err
+		}
+	}
+	if vf, ok := versionField(val.Type()); ok {
+		if _, set := data[vf.Name]; !set {
+			current, _ := entityVersion(obj)
+			if err := setPath(val, vf.Name, current+1); err != nil {
+				return err
+			}
+		}
+	}
+	if touchesUnique(stored.UniqFields(), data) {
+		if err := r.checkUnique(id, obj.UniqFields()); err != nil {
+			return err
+		}
+	}
+	r.docs[id] = obj
+	r.revisions[id]++
+	r.audit(ctx, id, AuditUpdate, updatedChanges(data, entityValue(stored)))
+	r.notify()
+	return nil
+}
+
+func (r *memoryRepository[T, TT]) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.opts.softDelete {
+		return r.delete(ctx, id)
+	}
+	stored, ok := r.docs[id]
+	if !ok {
+		return r.notFound(id)
+	}
+	if err := r.beforeDelete(ctx, id, stored); err != nil {
+		return err
+	}
+	now := time.Now()
+	r.deleted[id] = now
+	r.setDeletedField(id, stored, now)
+	r.audit(ctx, id, AuditDelete, map[string]AuditChange{r.opts.softDeleteField: {After: now}})
+	r.notify()
+	return nil
+}
+
+func (r *memoryRepository[T, TT]) Restore(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+	if !r.opts.softDelete {
+		return fmt.Errorf("soft delete is not enabled for %s", r.Ressource)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.docs[id]
+	if !ok {
+		return r.notFound(id)
+	}
+	if r.opts.excludesDeletedFromUnique() && r.isDeleted(id) {
+		if err := r.checkUnique(id, stored.UniqFields()); err != nil {
+			return err
+		}
+	}
+	deletedAt, wasDeleted := r.deleted[id]
+	delete(r.deleted, id)
+	r.setDeletedField(id, stored, nil)
+	if wasDeleted {
+		r.audit(ctx, id, AuditRestore, map[string]AuditChange{r.opts.softDeleteField: {Before: deletedAt}})
+		r.notify()
+	}
+	return nil
+}
+
+func (r *memoryRepository[T, TT]) Purge(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.delete(ctx, id)
+}
+
+// CreateMany stores objs one by one. Like CreateEasy it does not check UniqFields.
+func (r *memoryRepository[T, TT]) CreateMany(ctx context.Context, objs []T) ([]BulkResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return bulkEach(len(objs), r.opts.bulkConcurrency, func(i int) (string, error) {
+		docID, err := r.CreateEasy(ctx, objs[i])
+		if err != nil {
+			return "", err
+		}
+		return *docID, nil
+	}), nil
+}
+
+// UpdateMany applies every update and stamps UpdatedAt like Create does.
+func (r *memoryRepository[T, TT]) UpdateMany(ctx context.Context, updates []BulkUpdate) ([]BulkResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	return bulkEach(len(updates), r.opts.bulkConcurrency, func(i int) (string, error) {
+		return updates[i].ID, r.Update(ctx, updates[i].ID, stampUpdatedAt(t, updates[i].Data))
+	}), nil
+}
+
+// DeleteMany deletes, or soft deletes, every id.
+func (r *memoryRepository[T, TT]) DeleteMany(ctx context.Context, ids []string) ([]BulkResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return bulkEach(len(ids), r.opts.bulkConcurrency, func(i int) (string, error) {
+		return ids[i], r.Delete(ctx, ids[i])
+	}), nil
+}
+
+// delete runs BeforeDelete and removes the document. Missing documents return ErrorNotFound.
+func (r *memoryRepository[T, TT]) delete(ctx context.Context, id string) error {
+	stored, ok := r.docs[id]
+	if !ok {
+		return r.notFound(id)
+	}
+	if err := r.beforeDelete(ctx, id, stored); err != nil {
+		return err
+	}
+	r.purge(id)
+	r.audit(ctx, id, AuditPurge, removedChanges(entityFields(stored)))
+	r.notify()
+	return nil
+}
+
+func (r *memoryRepository[T, TT]) beforeDelete(ctx context.Context, id string, stored T) error {
+	if !implements[BeforeDeleter, T]() {
+		return nil
+	}
+	obj, err := r.load(ctx, id, stored)
+	if err != nil {
+		return err
+	}
+	return beforeDelete(ctx, obj)
+}
+
+func (r *memoryRepository[T, TT]) purge(id string) {
+	delete(r.docs, id)
+	delete(r.deleted, id)
+	delete(r.revisions, id)
+}
+
+// audit records op on the document id if auditing is enabled.
+func (r *memoryRepository[T, TT]) audit(ctx context.Context, id string, op AuditOperation, changes map[string]AuditChange) {
+	if r.history != nil {
+		_, _ = r.history.CreateEasy(ctx, newAuditRecord(ctx, id, op, changes))
+	}
+}
+
+// History lists the audit records of the document id, newest first unless opts sets an order.
+func (r *memoryRepository[T, TT]) History(ctx context.Context, id string, opts *query.QueryOptions) (*PaginationResult[*AuditRecord], error) {
+	if id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	if r.history == nil {
+		return nil, fmt.Errorf("audit is not enabled for %s", r.Ressource)
+	}
+
+	opts = historyOptions(opts)
+	filtered := *opts
+	filtered.Filters = append(slices.Clone(opts.Filters), query.Filter{Field: "entity_id", Operator: query.Eq, Value: id})
+	res, err := r.history.Get(ctx, &filtered)
+	if err != nil {
+		return nil, err
+	}
+	res.Filters = &opts.Filters
+	return res, nil
+}
+
+// notify wakes up all watchers. The caller must hold the write lock.
+func (r *memoryRepository[T, TT]) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Watch compares the matching documents with the last emitted state after every write.
+func (r *memoryRepository[T, TT]) Watch(ctx context.Context, opts *query.QueryOptions) iter.Seq2[WatchEvent[T], error] {
+	return func(yield func(WatchEvent[T], error) bool) {
+		if opts == nil {
+			opts = &query.QueryOptions{}
+		}
+
+		known := make(map[string]watchState[T])
+		for {
+			current, changed, err := r.snapshot(ctx, opts)
+			if err != nil {
+				yield(WatchEvent[T]{}, err)
+				return
+			}
+			for _, event := range diffSnapshot(known, current) {
+				if !yield(event, nil) {
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			}
+		}
+	}
+}
+
+// snapshot returns the watched documents and a channel that is closed on the next write.
+func (r *memoryRepository[T, TT]) snapshot(ctx context.Context, opts *query.QueryOptions) ([]watchState[T], <-chan struct{}, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	docs := r.matching(opts)
+	if opts.Limit > 0 && len(docs) > opts.Limit {
+		docs = docs[:opts.Limit]
+	}
+	current := make([]watchState[T], 0, len(docs))
+	for _, doc := range docs {
+		obj, err := r.load(ctx, doc.id, doc.obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		current = append(current, watchState[T]{
+			id:      doc.id,
+			version: fmt.Sprint(r.revisions[doc.id], r.deleted[doc.id]),
+			obj:     obj,
+		})
+	}
+	return current, r.changed, nil
+}
+
+func (r *memoryRepository[T, TT]) isDeleted(id string) bool {
+	_, ok := r.deleted[id]
+	return ok
+}
+
+// setDeletedField mirrors the deletion timestamp into the entity if it declares the soft delete field.
+func (r *memoryRepository[T, TT]) setDeletedField(id string, stored T, value interface{}) {
+	obj := cloneEntity(stored)
+	if err := setPath(reflect.ValueOf(&obj).Elem(), r.opts.softDeleteField, value); err == nil {
+		r.docs[id] = obj
+	}
+}
+
+func (r *memoryRepository[T, TT]) notFound(id string) error {
+	return &errors.ErrorNotFound{
+		ErrorDetail: errors.ErrorDetail{
+			Resource: r.Ressource,
+			Field:    "id",
+			Value:    id,
+			Message:  r.Ressource + " with id " + id + " not found",
+		},
+	}
+}
+
+// cloneEntity returns a shallow copy of obj so stored documents are not shared with callers.
+func cloneEntity[T any](obj T) T {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return obj
+	}
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+	return c.Interface().(T)
+}
+
+const docIDAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// newDocID generates a random 20 character ID like Firestore's NewDoc.
+func newDocID() string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("repository: crypto/rand.Read error: %v", err))
+	}
+	for i := range b {
+		b[i] = docIDAlphabet[int(b[i])%len(docIDAlphabet)]
+	}
+	return string(b)
+}
+
+// docLess orders documents by the given field and falls back to the document ID, like Firestore's implicit ordering.
+func docLess[T any](orderBy string, direction query.Direction) func(a, b memoryDoc[T]) bool {
+	return func(a, b memoryDoc[T]) bool {
+		c := 0
+		if orderBy != "" && orderBy != "id" {
+			av, _ := lookupPath(reflect.ValueOf(a.obj), orderBy)
+			bv, _ := lookupPath(reflect.ValueOf(b.obj), orderBy)
+			c = compareValues(av, bv)
+		}
+		if c == 0 {
+			c = strings.Compare(a.id, b.id)
+		}
+		if orderBy != "" && direction == query.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+}
+
+func filterDocs[T any](docs []memoryDoc[T], keep func(memoryDoc[T]) bool) []memoryDoc[T] {
+	filtered := docs[:0]
+	for _, doc := range docs {
+		if keep(doc) {
+			filtered = append(filtered, doc)
+		}
+	}
+	return filtered
+}
+
+func matchesFilters(id string, obj interface{}, filters []query.Filter) bool {
+	for _, f := range filters {
+		if f.Field == "id" {
+			if s, ok := f.Value.(string); !ok || s != id {
+				return false
+			}
+			continue
+		}
+		fv, ok := lookupPath(reflect.ValueOf(obj), f.Field)
+		if !ok || !matchesFilter(fv, f.Operator, f.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAll(obj interface{}, fields map[string]interface{}) bool {
+	for path, value := range fields {
+		fv, ok := lookupPath(reflect.ValueOf(obj), path)
+		if !ok || !matchesFilter(fv, query.Eq, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesFilter(field reflect.Value, op query.Operator, value interface{}) bool {
+	filter := reflect.ValueOf(value)
+	switch op {
+	case query.Contains:
+		f, ok := indirect(filter)
+		if !ok || (f.Kind() != reflect.Slice && f.Kind() != reflect.Array) {
+			return false
+		}
+		for i := 0; i < f.Len(); i++ {
+			if equalValues(field, f.Index(i)) {
+				return true
+			}
+		}
+		return false
+	case query.ArrayContains:
+		f, ok := indirect(field)
+		if !ok || (f.Kind() != reflect.Slice && f.Kind() != reflect.Array) {
+			return false
+		}
+		for i := 0; i < f.Len(); i++ {
+			if equalValues(f.Index(i), filter) {
+				return true
+			}
+		}
+		return false
+	case query.Gt, query.Gte, query.Lt, query.Lte:
+		if valueRank(field) != valueRank(filter) {
+			return false
+		}
+		c := compareValues(field, filter)
+		switch op {
+		case query.Gt:
+			return c > 0
+		case query.Gte:
+			return c >= 0
+		case query.Lt:
+			return c < 0
+		default:
+			return c <= 0
+		}
+	default:
+		return equalValues(field, filter)
+	}
+}
+
+func equalValues(a, b reflect.Value) bool {
+	ra, rb := valueRank(a), valueRank(b)
+	if ra != rb {
+		return false
+	}
+	if ra == rankOther {
+		a, _ = indirect(a)
+		b, _ = indirect(b)
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+	return compareValues(a, b) == 0
+}
+
+const (
+	rankNull = iota
+	rankBool
+	rankNumber
+	rankTime
+	rankString
+	rankOther
+)
+
+// valueRank orders value types the way Firestore does for mixed-type comparisons.
+func valueRank(v reflect.Value) int {
+	v, ok := indirect(v)
+	if !ok {
+		return rankNull
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return rankBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return rankNumber
+	case reflect.String:
+		return rankString
+	}
+	if v.Type() == timeType {
+		return rankTime
+	}
+	return rankOther
+}
+
+func compareValues(a, b reflect.Value) int {
+	ra, rb := valueRank(a), valueRank(b)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+	a, _ = indirect(a)
+	b, _ = indirect(b)
+	switch ra {
+	case rankBool:
+		switch {
+		case a.Bool() == b.Bool():
+			return 0
+		case !a.Bool():
+			return -1
+		default:
+			return 1
+		}
+	case rankNumber:
+		fa, fb := toFloat(a), toFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		default:
+			return 0
+		}
+	case rankTime:
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time))
+	case rankString:
+		return strings.Compare(a.String(), b.String())
+	}
+	return 0
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
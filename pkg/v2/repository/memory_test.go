@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+type testUser struct {
+	ID        string    `firestore:"-"`
+	Email     string    `firestore:"email" validate:"required,email"`
+	Name      string    `firestore:"name" validate:"required"`
+	Age       int       `firestore:"age" validate:"gte=0"`
+	Tags      []string  `firestore:"tags"`
+	CreatedAt time.Time `firestore:"created_at"`
+	UpdatedAt time.Time `firestore:"updated_at"`
+}
+
+func (u *testUser) DocId() string {
+	return u.ID
+}
+
+func (u *testUser) SetDocId(id string) {
+	u.ID = id
+}
+
+func (u *testUser) UniqFields() map[string]interface{} {
+	return map[string]interface{}{
+		"email": u.Email,
+	}
+}
+
+func seedUsers(t *testing.T, repo Repository[*testUser, testUser], users ...*testUser) []string {
+	t.Helper()
+	ids := make([]string, 0, len(users))
+	for _, u := range users {
+		id, err := repo.Create(context.Background(), u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, *id)
+	}
+	return ids
+}
+
+func TestMemoryRepositoryCreate(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User")
+
+	user := &testUser{Email: "a@example.com", Name: "A"}
+	id, err := repo.Create(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.CreatedAt.IsZero() || user.UpdatedAt.IsZero() {
+		t.Errorf("Expected CreatedAt and UpdatedAt to be set")
+	}
+
+	got, err := repo.GetByID(ctx, *id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*got).ID != *id {
+		t.Errorf("Expected id %s, got %s", *id, (*got).ID)
+	}
+	if (*got).Email != "a@example.com" {
+		t.Errorf("Expected a@example.com, got %s", (*got).Email)
+	}
+
+	(*got).Name = "changed"
+	again, _ := repo.GetByID(ctx, *id)
+	if (*again).Name != "A" {
+		t.Errorf("Expected stored document to be isolated from callers, got %s", (*again).Name)
+	}
+
+	_, err = repo.Create(ctx, &testUser{Email: "a@example.com"})
+	if _, ok := err.(*errors.ErrorAlreadyExists); !ok {
+		t.Errorf("Expected ErrorAlreadyExists, got %v", err)
+	}
+
+	if _, err := repo.CreateEasy(ctx, &testUser{Email: "a@example.com"}); err != nil {
+		t.Errorf("Expected CreateEasy to skip the uniqueness check, got %v", err)
+	}
+}
+
+func TestMemoryRepositoryGetByIDNotFound(t *testing.T) {
+	repo := NewMemoryRepository[*testUser, testUser]("User")
+
+	_, err := repo.GetByID(context.Background(), "missing")
+	if _, ok := err.(*errors.ErrorNotFound); !ok {
+		t.Errorf("Expected ErrorNotFound, got %v", err)
+	}
+}
+
+func TestMemoryRepositoryGetFilters(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User")
+	seedUsers(t, repo,
+		&testUser{Email: "a@example.com", Name: "A", Age: 20, Tags: []string{"admin"}},
+		&testUser{Email: "b@example.com", Name: "B", Age: 30},
+		&testUser{Email: "c@example.com", Name: "C", Age: 40, Tags: []string{"admin", "staff"}},
+	)
+
+	tests := []struct {
+		name   string
+		filter query.Filter
+		want   []string
+	}{
+		{"eq", query.Filter{Field: "name", Operator: query.Eq, Value: "B"}, []string{"B"}},
+		{"gt", query.Filter{Field: "age", Operator: query.Gt, Value: 20}, []string{"B", "C"}},
+		{"gte", query.Filter{Field: "age", Operator: query.Gte, Value: 30}, []string{"B", "C"}},
+		{"lt", query.Filter{Field: "age", Operator: query.Lt, Value: 30}, []string{"A"}},
+		{"lte", query.Filter{Field: "age", Operator: query.Lte, Value: int64(30)}, []string{"A", "B"}},
+		{"contains", query.Filter{Field: "name", Operator: query.Contains, Value: []string{"A", "C"}}, []string{"A", "C"}},
+		{"array-contains", query.Filter{Field: "tags", Operator: query.ArrayContains, Value: "staff"}, []string{"C"}},
+		{"type mismatch", query.Filter{Field: "age", Operator: query.Eq, Value: "20"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := repo.Get(ctx, &query.QueryOptions{
+				Limit:   10,
+				OrderBy: "name",
+				Filters: []query.Filter{tt.filter},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Items) != len(tt.want) {
+				t.Fatalf("Expected %d items, got %d", len(tt.want), len(res.Items))
+			}
+			for i, name := range tt.want {
+				if res.Items[i].Name != name {
+					t.Errorf("Expected %s at %d, got %s", name, i, res.Items[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryRepositoryPagination(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User")
+	seedUsers(t, repo,
+		&testUser{Email: "1", Age: 1},
+		&testUser{Email: "2", Age: 2},
+		&testUser{Email: "3", Age: 3},
+		&testUser{Email: "4", Age: 4},
+		&testUser{Email: "5", Age: 5},
+	)
+
+	opts := &query.QueryOptions{Limit: 2, OrderBy: "age", OrderByDirection: query.Desc}
+	first, err := repo.Get(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Items) != 2 || first.Items[0].Age != 5 || first.Items[1].Age != 4 {
+		t.Fatalf("Unexpected first page %+v", first.Items)
+	}
+	if first.Next == "" || first.Prev != "" || !first.HasNext || first.HasPrev {
+		t.Errorf("Expected next and no prev on the first page, got next=%q prev=%q", first.Next, first.Prev)
+	}
+
+	opts.Next = first.Next
+	second, err := repo.Get(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Items) != 2 || second.Items[0].Age != 3 || second.Items[1].Age != 2 {
+		t.Fatalf("Unexpected second page %+v", second.Items)
+	}
+	if !second.HasPrev || !second.HasNext {
+		t.Errorf("Expected prev and next on the second page, got %+v", second)
+	}
+
+	back, err := repo.Get(ctx, &query.QueryOptions{Limit: 2, OrderBy: "age", OrderByDirection: query.Desc, Previous: second.Prev})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Items) != 2 || back.Items[0].Age != 5 || back.Items[1].Age != 4 || back.HasPrev || back.Next == "" {
+		t.Fatalf("Expected prev to return the first page, got %+v", back)
+	}
+
+	opts.Next = second.Next
+	third, err := repo.Get(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(third.Items) != 1 || third.Items[0].Age != 1 {
+		t.Fatalf("Unexpected third page %+v", third.Items)
+	}
+	if third.Next != "" || third.HasNext {
+		t.Errorf("Expected no next on the last page, got %s", third.Next)
+	}
+
+	back, err = repo.Get(ctx, &query.QueryOptions{Limit: 2, OrderBy: "age", OrderByDirection: query.Desc, Previous: third.Prev})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Items) != 2 || back.Items[0].Age != 3 || back.Items[1].Age != 2 || !back.HasPrev {
+		t.Fatalf("Expected prev to return the two items before the last page, got %+v", back.Items)
+	}
+
+	_, err = repo.Get(ctx, &query.QueryOptions{Limit: 2, Next: "missing"})
+	if _, ok := err.(*errors.ErrorBadRequest); !ok {
+		t.Errorf("Expected ErrorBadRequest for an invalid cursor, got %v", err)
+	}
+	_, err = repo.Get(ctx, &query.QueryOptions{Limit: 2, OrderBy: "age", Next: first.Next})
+	if _, ok := err.(*errors.ErrorBadRequest); !ok {
+		t.Errorf("Expected ErrorBadRequest for a cursor of another order, got %v", err)
+	}
+}
+
+func TestMemoryRepositoryUpdateAndDelete(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User")
+	ids := seedUsers(t, repo, &testUser{Email: "a@example.com", Name: "A", Age: 1})
+
+	err := repo.Update(ctx, ids[0], map[string]interface{}{"name": "Z", "age": int64(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetByID(ctx, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*got).Name != "Z" || (*got).Age != 7 {
+		t.Errorf("Expected updated fields, got %+v", *got)
+	}
+
+	if err := repo.Update(ctx, ids[0], map[string]interface{}{"unknown": 1}); err == nil {
+		t.Errorf("Expected error for unknown field")
+	}
+	if _, ok := repo.Update(ctx, "missing", map[string]interface{}{"name": "Z"}).(*errors.ErrorNotFound); !ok {
+		t.Errorf("Expected ErrorNotFound when updating a missing document")
+	}
+
+	if err := repo.Delete(ctx, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetByID(ctx, ids[0]); err == nil {
+		t.Errorf("Expected deleted document to be gone")
+	}
+}
+
+func TestMemoryRepositorySoftDelete(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("tombstones block uniqueness", func(t *testing.T) {
+		repo := NewMemoryRepository[*testUser, testUser]("User", WithSoftDelete())
+		ids := seedUsers(t, repo, &testUser{Email: "a@example.com"}, &testUser{Email: "b@example.com"})
+
+		if err := repo.Delete(ctx, ids[0]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetByID(ctx, ids[0]); err == nil {
+			t.Errorf("Expected deleted document to be hidden from GetByID")
+		}
+		res, _ := repo.Get(ctx, &query.QueryOptions{Limit: 10})
+		if len(res.Items) != 1 {
+			t.Errorf("Expected 1 visible document, got %d", len(res.Items))
+		}
+		res, _ = repo.Get(ctx, &query.QueryOptions{Limit: 10, IncludeDeleted: true})
+		if len(res.Items) != 2 {
+			t.Errorf("Expected 2 documents including deleted, got %d", len(res.Items))
+		}
+		if _, err := repo.Create(ctx, &testUser{Email: "a@example.com"}); err == nil {
+			t.Errorf("Expected deleted document to keep blocking its unique values")
+		}
+
+		if err := repo.Restore(ctx, ids[0]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetByID(ctx, ids[0]); err != nil {
+			t.Errorf("Expected restored document to be visible, got %v", err)
+		}
+
+		if err := repo.Purge(ctx, ids[0]); err != nil {
+			t.Fatal(err)
+		}
+		res, _ = repo.Get(ctx, &query.QueryOptions{Limit: 10, IncludeDeleted: true})
+		if len(res.Items) != 1 {
+			t.Errorf("Expected purged document to be gone, got %d", len(res.Items))
+		}
+	})
+
+	t.Run("tombstones ignored for uniqueness", func(t *testing.T) {
+		repo := NewMemoryRepository[*testUser, testUser]("User", WithSoftDelete(), WithUniqueIgnoringDeleted())
+		ids := seedUsers(t, repo, &testUser{Email: "a@example.com"})
+
+		if err := repo.Delete(ctx, ids[0]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Create(ctx, &testUser{Email: "a@example.com"}); err != nil {
+			t.Fatalf("Expected deleted document to release its unique values, got %v", err)
+		}
+		if _, ok := repo.Restore(ctx, ids[0]).(*errors.ErrorAlreadyExists); !ok {
+			t.Errorf("Expected restore to fail while the unique value is taken")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		repo := NewMemoryRepository[*testUser, testUser]("User")
+		ids := seedUsers(t, repo, &testUser{Email: "a@example.com"})
+
+		if err := repo.Restore(ctx, ids[0]); err == nil {
+			t.Errorf("Expected Restore to fail without soft delete")
+		}
+		if err := repo.Delete(ctx, ids[0]); err != nil {
+			t.Fatal(err)
+		}
+		res, _ := repo.Get(ctx, &query.QueryOptions{Limit: 10, IncludeDeleted: true})
+		if len(res.Items) != 0 {
+			t.Errorf("Expected Delete to remove the document, got %d", len(res.Items))
+		}
+	})
+}
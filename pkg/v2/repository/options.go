@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Option configures optional repository behavior.
+type Option func(*options)
+
+type options struct {
+	softDelete           bool
+	softDeleteField      string
+	uniqueIgnoresDeleted bool
+	uniqueConstraints    bool
+	bulkConcurrency      int
+	validator            *validator.Validate
+	collectionName       string
+	pluralize            func(ressource string) string
+	collectionPrefix     string
+	collectionSuffix     string
+	uniqueScope          []string
+	audit                bool
+	auditCollection      string
+	auditSubcollection   bool
+	cursorKey            []byte
+	keys                 KeyProvider
+}
+
+const defaultSoftDeleteField = "deleted_at"
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		softDeleteField: defaultSoftDeleteField,
+		bulkConcurrency: defaultBulkConcurrency,
+		pluralize:       defaultPluralize,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithSoftDelete makes Delete set a "deleted_at" timestamp instead of removing the document.
+// Deleted documents are hidden from Get and GetByID unless QueryOptions.IncludeDeleted is set.
+// Existing Firestore collections need BackfillSoftDelete, as queries skip documents without the field.
+func WithSoftDelete() Option {
+	return func(o *options) {
+		o.softDelete = true
+	}
+}
+
+// WithSoftDeleteField enables soft delete using the given field for the deletion timestamp.
+func WithSoftDeleteField(field string) Option {
+	return func(o *options) {
+		o.softDelete = true
+		o.softDeleteField = field
+	}
+}
+
+// WithUniqueIgnoringDeleted makes uniqueness checks skip soft deleted documents.
+// By default deleted documents keep blocking their unique values.
+func WithUniqueIgnoringDeleted() Option {
+	return func(o *options) {
+		o.uniqueIgnoresDeleted = true
+	}
+}
+
+// WithUniqueConstraints treats every UniqFields entry as its own unique constraint, instead of
+// rejecting a document only when all entries collide. Firestore enforces them with reservation
+// documents in the collection "<collection>_unique", written in the same transaction as the entity.
+// Zero values are not reserved, and CreateEasy and CreateMany still skip the check.
+func WithUniqueConstraints() Option {
+	return func(o *options) {
+		o.uniqueConstraints = true
+	}
+}
+
+// WithUniqueScope limits WithUniqueConstraints to documents sharing the values of the scope fields,
+// e.g. a tenant ID. Scope fields must be part of UniqFields; they are added to every constraint
+// instead of being unique on their own. Without WithUniqueConstraints all UniqFields are already
+// combined, so including the scope fields there is enough.
+func WithUniqueScope(fields ...string) Option {
+	return func(o *options) {
+		o.uniqueScope = append(o.uniqueScope, fields...)
+	}
+}
+
+// WithAudit records every create, update and delete in the collection "<collection>_history",
+// in the same transaction as the write. Records carry the actor from ContextWithActor and the
+// changed fields; History lists them. Writes that would otherwise skip the transaction, including
+// the bulk operations, then run one by one.
+func WithAudit() Option {
+	return func(o *options) {
+		o.audit = true
+	}
+}
+
+// WithAuditCollection enables auditing into the given top level collection. WithCollectionPrefix
+// and WithCollectionSuffix apply to it as well.
+func WithAuditCollection(name string) Option {
+	return func(o *options) {
+		o.audit = true
+		o.auditCollection = name
+		o.auditSubcollection = false
+	}
+}
+
+// WithAuditSubcollection enables auditing into a subcollection of every audited document.
+func WithAuditSubcollection(name string) Option {
+	return func(o *options) {
+		o.audit = true
+		o.auditCollection = name
+		o.auditSubcollection = true
+	}
+}
+
+// WithBulkConcurrency limits how many writers CreateMany, UpdateMany and DeleteMany run in parallel.
+// The default is 10.
+func WithBulkConcurrency(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.bulkConcurrency = n
+		}
+	}
+}
+
+// WithValidator checks entities against their `validate` struct tags before Create, CreateEasy,
+// CreateQueryNotExists and CreateMany write them, after any BeforeCreate hook. Patch validates
+// only the masked fields. Failures are returned as validator.ValidationErrors, which
+// errors.NewErrorResponse reports as 400.
+func WithValidator(v *validator.Validate) Option {
+	return func(o *options) {
+		o.validator = v
+	}
+}
+
+// WithCollectionName sets the collection, or SQL table, name instead of deriving it from the ressource.
+// Prefix and suffix are still applied.
+func WithCollectionName(name string) Option {
+	return func(o *options) {
+		o.collectionName = name
+	}
+}
+
+// WithPluralizer derives the collection name from the ressource name with pluralize.
+// The default lowercases the ressource and appends "s".
+func WithPluralizer(pluralize func(ressource string) string) Option {
+	return func(o *options) {
+		if pluralize != nil {
+			o.pluralize = pluralize
+		}
+	}
+}
+
+// WithCollectionPrefix prepends prefix to the collection name, e.g. "staging_" for "staging_users".
+func WithCollectionPrefix(prefix string) Option {
+	return func(o *options) {
+		o.collectionPrefix = prefix
+	}
+}
+
+// WithCollectionSuffix appends suffix to the collection name.
+func WithCollectionSuffix(suffix string) Option {
+	return func(o *options) {
+		o.collectionSuffix = suffix
+	}
+}
+
+// WithCursorKey signs the Next and Prev page tokens with HMAC-SHA256 under key. Get rejects
+// tokens that were not signed with the same key, so clients cannot craft their own cursors.
+func WithCursorKey(key []byte) Option {
+	return func(o *options) {
+		if len(key) > 0 {
+			o.cursorKey = key
+		}
+	}
+}
+
+// WithEncryption encrypts string fields tagged `encrypt:"true"` with AES-GCM under the keys of
+// keys before Firestore and SQL repositories store them, and decrypts them when loading. Fields
+// tagged `encrypt:"deterministic"` encrypt equal values to equal ciphertexts, so they can be
+// used in equality filters and UniqFields. The in-memory repository keeps values in plain text.
+func WithEncryption(keys KeyProvider) Option {
+	return func(o *options) {
+		o.keys = keys
+	}
+}
+
+func defaultPluralize(ressource string) string {
+	return strings.ToLower(ressource) + "s"
+}
+
+// collection returns the collection name for ressource.
+func (o *options) collection(ressource string) string {
+	name := o.collectionName
+	if name == "" {
+		name = o.pluralize(ressource)
+	}
+	return o.collectionPrefix + name + o.collectionSuffix
+}
+
+// excludesDeletedFromUnique reports whether uniqueness checks must skip soft deleted documents.
+func (o *options) excludesDeletedFromUnique() bool {
+	return o.softDelete && o.uniqueIgnoresDeleted
+}
+
+// configured is implemented by the repositories built from Options, so that decorators can check
+// how the repository they wrap is set up.
+type configured interface {
+	configuration() *options
+}
+
+// configurationOf returns the options of repo, or nil if they are unknown.
+func configurationOf(repo interface{}) *options {
+	if c, ok := repo.(configured); ok {
+		return c.configuration()
+	}
+	return nil
+}
+
+// uniqueGroups splits unique values into the sets that must not collide as a whole:
+// one set of all values by default, or one per non-zero field with WithUniqueConstraints,
+// each combined with the scope fields.
+func (o *options) uniqueGroups(uniq map[string]interface{}) []map[string]interface{} {
+	if !o.uniqueConstraints {
+		return []map[string]interface{}{uniq}
+	}
+	scope := o.scopeValues(uniq)
+	groups := make([]map[string]interface{}, 0, len(uniq))
+	for _, field := range sortedKeys(uniq) {
+		if _, ok := scope[field]; ok || isZeroValue(uniq[field]) {
+			continue
+		}
+		group := map[string]interface{}{field: uniq[field]}
+		for k, v := range scope {
+			group[k] = v
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// scopeValues returns the values of the unique scope fields present in uniq.
+func (o *options) scopeValues(uniq map[string]interface{}) map[string]interface{} {
+	scope := make(map[string]interface{}, len(o.uniqueScope))
+	for _, field := range o.uniqueScope {
+		if value, ok := uniq[field]; ok {
+			scope[field] = value
+		}
+	}
+	return scope
+}
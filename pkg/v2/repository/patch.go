@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+)
+
+// patchData converts the masked fields of patch into update data for the entity type t.
+// The mask holds Go field names, nested fields are separated by dots ("Address.City").
+// Without a mask every non-zero top level field of patch is used. Fields are mapped to
+// their Firestore names and must exist on t. UpdatedAt is stamped unless the patch sets it.
+func patchData(ressource string, t reflect.Type, patch interface{}, mask []string) (map[string]interface{}, error) {
+	val, ok := indirect(reflect.ValueOf(patch))
+	if !ok || val.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("patch must be a struct or a pointer to a struct")
+	}
+
+	mask = patchMask(val, mask)
+	data := make(map[string]interface{}, len(mask)+1)
+	for _, goPath := range mask {
+		chain, ok := goFieldChain(val.Type(), goPath)
+		if !ok {
+			return nil, unknownField(ressource, goPath)
+		}
+		names := make([]string, len(chain))
+		for i, f := range chain {
+			names[i] = f.Name
+		}
+		if !hasFieldPath(t, names) {
+			return nil, unknownField(ressource, goPath)
+		}
+		data[strings.Join(names, ".")] = chainValue(val, chain)
+	}
+	return stampUpdatedAt(t, data), nil
+}
+
+// patchMask returns mask, or the names of all non-zero top level fields of val if mask is empty.
+func patchMask(val reflect.Value, mask []string) []string {
+	if len(mask) > 0 {
+		return mask
+	}
+	for _, f := range structFields(val.Type()) {
+		if fv, err := val.FieldByIndexErr(f.Index); err == nil && !fv.IsZero() {
+			mask = append(mask, f.GoName)
+		}
+	}
+	return mask
+}
+
+// goFieldChain resolves a dotted path of Go field names to the persisted fields along it.
+func goFieldChain(t reflect.Type, goPath string) ([]fieldInfo, bool) {
+	var chain []fieldInfo
+	for _, part := range strings.Split(goPath, ".") {
+		found := false
+		for _, f := range structFields(t) {
+			if f.GoName == part {
+				chain = append(chain, f)
+				t, found = f.Type, true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return chain, true
+}
+
+// hasFieldPath reports whether the Firestore path names exists on t. Map fields accept any key below them.
+func hasFieldPath(t reflect.Type, names []string) bool {
+	for _, name := range names {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Map {
+			return t.Key().Kind() == reflect.String
+		}
+		found := false
+		for _, f := range structFields(t) {
+			if f.Name == name {
+				t, found = f.Type, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// chainValue reads the field at the end of chain, returning nil if a pointer along the way is nil.
+func chainValue(v reflect.Value, chain []fieldInfo) interface{} {
+	for _, f := range chain {
+		var ok bool
+		if v, ok = indirect(v); !ok {
+			return nil
+		}
+		fv, err := v.FieldByIndexErr(f.Index)
+		if err != nil {
+			return nil
+		}
+		v = fv
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+func unknownField(ressource, field string) error {
+	return &errors.ErrorBadRequest{
+		ErrorDetail: errors.ErrorDetail{
+			Resource: ressource,
+			Field:    field,
+			Message:  ressource + " has no field " + field,
+		},
+	}
+}
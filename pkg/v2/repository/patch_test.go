@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+)
+
+type testPatchAddress struct {
+	City string `firestore:"city"`
+}
+
+type testPatchCustomer struct {
+	ID      string            `firestore:"-"`
+	Name    string            `firestore:"name"`
+	Address *testPatchAddress `firestore:"address"`
+	Labels  map[string]string `firestore:"labels"`
+}
+
+type testPatchTier struct {
+	Tier string `firestore:"tier"`
+}
+
+type testPatchLabels struct {
+	Labels testPatchTier `firestore:"labels"`
+}
+
+func TestPatchData(t *testing.T) {
+	customerType := reflect.TypeOf(&testPatchCustomer{})
+
+	tests := []struct {
+		name    string
+		patch   interface{}
+		mask    []string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:  "partial entity",
+			patch: &testPatchCustomer{Name: "A", Address: &testPatchAddress{City: "Berlin"}},
+			mask:  []string{"Name", "Address.City"},
+			want:  map[string]interface{}{"name": "A", "address.city": "Berlin"},
+		},
+		{
+			name:  "zero value in mask",
+			patch: testPatchCustomer{},
+			mask:  []string{"Name", "Address.City"},
+			want:  map[string]interface{}{"name": "", "address.city": nil},
+		},
+		{
+			name:  "map key",
+			patch: testPatchLabels{Labels: testPatchTier{Tier: "gold"}},
+			mask:  []string{"Labels.Tier"},
+			want:  map[string]interface{}{"labels.tier": "gold"},
+		},
+		{
+			name:    "unknown go field",
+			patch:   testPatchCustomer{},
+			mask:    []string{"name"},
+			wantErr: true,
+		},
+		{
+			name:    "not a struct",
+			patch:   "name",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patchData("Customer", customerType, tt.patch, tt.mask)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("patchData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMemoryRepositoryPatch(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User")
+	ids := seedUsers(t, repo, &testUser{Email: "a@example.com", Name: "A", Age: 1})
+	before, _ := repo.GetByID(ctx, ids[0])
+
+	if err := repo.Patch(ctx, ids[0], &testUser{Name: "B", Age: 0}, "Name", "Age"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := repo.GetByID(ctx, ids[0])
+	if (*got).Name != "B" || (*got).Age != 0 || (*got).Email != "a@example.com" {
+		t.Errorf("Expected only the masked fields to change, got %+v", *got)
+	}
+	if !(*got).UpdatedAt.After((*before).UpdatedAt) {
+		t.Errorf("Expected UpdatedAt to be stamped")
+	}
+
+	name := "C"
+	if err := repo.Patch(ctx, ids[0], struct {
+		Name *string `firestore:"name"`
+		Age  *int    `firestore:"age"`
+	}{Name: &name}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = repo.GetByID(ctx, ids[0])
+	if (*got).Name != "C" || (*got).Age != 0 {
+		t.Errorf("Expected patch struct to set only non-nil fields, got %+v", *got)
+	}
+
+	err := repo.Patch(ctx, ids[0], struct {
+		Nickname string `firestore:"nickname"`
+	}{Nickname: "x"})
+	if _, ok := err.(*errors.ErrorBadRequest); !ok {
+		t.Errorf("Expected ErrorBadRequest for a field unknown to the entity, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+// projection validates opts.Fields against the persisted fields of T and returns the paths Get
+// reads. The order field and the Version field are added, as cursors and versions need them; the
+// document ID is always read. It returns nil if opts selects no fields.
+func projection[T any](ressource string, opts *query.QueryOptions) ([]string, error) {
+	if len(opts.Fields) == 0 {
+		return nil, nil
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	paths := make([]string, 0, len(opts.Fields)+2)
+	add := func(path string) {
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	for _, field := range opts.Fields {
+		if field == "id" {
+			continue
+		}
+		if !hasFieldPath(t, strings.Split(field, ".")) {
+			return nil, &errors.ErrorBadRequest{
+				ErrorDetail: errors.ErrorDetail{
+					Resource: ressource,
+					Field:    "fields",
+					Value:    field,
+					Message:  ressource + " has no field " + field,
+				},
+			}
+		}
+		add(field)
+	}
+	if orderBy, _ := pageOrder(opts); orderBy != "id" {
+		add(orderBy)
+	}
+	if vf, ok := versionField(t); ok {
+		add(vf.Name)
+	}
+	return paths, nil
+}
+
+// project returns a new entity holding only the given paths of obj.
+func project[T any](obj T, paths []string) T {
+	projected := newEntity[T]()
+	val := reflect.ValueOf(&projected).Elem()
+	for _, path := range paths {
+		if v, ok := lookupPath(reflect.ValueOf(obj), path); ok {
+			_ = setPath(val, path, v.Interface())
+		}
+	}
+	return projected
+}
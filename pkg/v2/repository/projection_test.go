@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+func TestMemoryRepositoryFields(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User")
+	seedUsers(t, repo,
+		&testUser{Name: "A", Email: "a@example.com", Age: 20, Tags: []string{"x"}},
+		&testUser{Name: "B", Email: "b@example.com", Age: 30},
+	)
+
+	page, err := repo.Get(ctx, &query.QueryOptions{Limit: 1, OrderBy: "age", Fields: []string{"id", "name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := page.Items[0]
+	if user.ID == "" || user.Name != "A" || user.Age != 20 {
+		t.Errorf("Expected the ID, the name and the order field, got %+v", user)
+	}
+	if user.Email != "" || user.Tags != nil || !user.CreatedAt.IsZero() {
+		t.Errorf("Expected the other fields to be empty, got %+v", user)
+	}
+
+	next, err := repo.Get(ctx, &query.QueryOptions{Limit: 1, OrderBy: "age", Fields: []string{"name"}, Next: page.Next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Items) != 1 || next.Items[0].Name != "B" {
+		t.Errorf("Expected B on the next page, got %+v", next.Items)
+	}
+
+	if _, err := repo.Get(ctx, &query.QueryOptions{Fields: []string{"password"}}); !isBadRequest(err) {
+		t.Errorf("Expected ErrorBadRequest for an unknown field, got %v", err)
+	}
+}
+
+func TestSqlProjection(t *testing.T) {
+	table := newSqlTable("users", PostgresDialect, reflect.TypeOf(&testSqlUser{}))
+	paths, err := projection[*testSqlUser]("User", &query.QueryOptions{Fields: []string{"name", "profile.City"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := table.project(paths).selectQuery(&query.QueryOptions{Filters: []query.Filter{{Field: "age", Operator: query.Gt, Value: 1}}}, "", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT "id", "full_name", "profile" FROM "users" WHERE "age" > $1 ORDER BY "id" ASC`
+	if stmt != want {
+		t.Errorf("Expected %s, got %s", want, stmt)
+	}
+}
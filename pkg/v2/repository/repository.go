@@ -3,15 +3,22 @@ package repository
 import (
 	"context"
 	"fmt"
+	"iter"
+	"maps"
 	"reflect"
-	"strings"
+	"strconv"
 	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
 	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// ErrNotSupported is returned by repository implementations for operations their store cannot provide.
+var ErrNotSupported = fmt.Errorf("operation not supported by this repository")
+
 type Entity interface {
 	DocId() string
 	SetDocId(id string)
@@ -22,29 +29,64 @@ type Repository[T Entity, TT any] interface {
 	GetClient() *firestore.Client
 	Get(ctx context.Context, opts *query.QueryOptions) (*PaginationResult[T], error)
 	GetByID(ctx context.Context, id string) (*T, error)
+	GetByIDs(ctx context.Context, ids []string) ([]T, []string, error)
 	Create(ctx context.Context, obj T) (*string, error)
 	CreateEasy(ctx context.Context, obj T) (*string, error)
 	CreateQueryNotExists(ctx context.Context, obj T, funcQuery func(firestore.Query) firestore.Query) (*string, error)
+	Upsert(ctx context.Context, obj T) (*string, bool, error)
 	Update(ctx context.Context, id string, data map[string]interface{}) error
+	UpdateWithVersion(ctx context.Context, id string, version string, data map[string]interface{}) error
 	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) error
+	Purge(ctx context.Context, id string) error
+	CreateMany(ctx context.Context, objs []T) ([]BulkResult, error)
+	UpdateMany(ctx context.Context, updates []BulkUpdate) ([]BulkResult, error)
+	DeleteMany(ctx context.Context, ids []string) ([]BulkResult, error)
+	All(ctx context.Context, opts *query.QueryOptions) iter.Seq2[T, error]
+	Patch(ctx context.Context, id string, patch interface{}, mask ...string) error
+	History(ctx context.Context, id string, opts *query.QueryOptions) (*PaginationResult[*AuditRecord], error)
+	Watch(ctx context.Context, opts *query.QueryOptions) iter.Seq2[WatchEvent[T], error]
+	Count(ctx context.Context, opts *query.QueryOptions) (int64, error)
+	Aggregate(ctx context.Context, opts *query.QueryOptions, agg Aggregation) (*AggregationResult, error)
 }
 
 type repository[T Entity, TT any] struct {
 	Db         *firestore.Client
+	Parent     *firestore.DocumentRef
 	Collection string
 	Ressource  string
+	opts       *options
+	group      bool
 }
 
-func NewFirebaseRepository[T Entity, TT any](db *firestore.Client, ressoucre string) Repository[T, TT] {
-	collectionName := strings.ToLower(ressoucre) + "s"
+func NewFirebaseRepository[T Entity, TT any](db *firestore.Client, ressoucre string, opts ...Option) Repository[T, TT] {
+	o := newOptions(opts)
 
 	return &repository[T, TT]{
 		Db:         db,
-		Collection: collectionName,
+		Collection: o.collection(ressoucre),
 		Ressource:  ressoucre,
+		opts:       o,
+	}
+}
+
+// NewFirebaseSubcollectionRepository creates a repository for the ressource collection below the
+// parent document, e.g. customers/{id}/orders. Uniqueness checks only see documents of the same parent.
+func NewFirebaseSubcollectionRepository[T Entity, TT any](db *firestore.Client, parent *firestore.DocumentRef, ressource string, opts ...Option) Repository[T, TT] {
+	o := newOptions(opts)
+	return &repository[T, TT]{
+		Db:         db,
+		Parent:     parent,
+		Collection: o.collection(ressource),
+		Ressource:  ressource,
+		opts:       o,
 	}
 }
 
+func (r *repository[T, TT]) configuration() *options {
+	return r.opts
+}
+
 func (r *repository[T, TT]) GetClient() *firestore.Client {
 	return r.Db
 }
@@ -55,6 +97,9 @@ type PaginationResult[T any] struct {
 	Next    string          `json:"next"`
 	Prev    string          `json:"prev"`
 	Filters *[]query.Filter `json:"filters,omitempty"`
+	Total   *int64          `json:"total,omitempty"`
+	HasNext bool            `json:"has_next"`
+	HasPrev bool            `json:"has_prev"`
 }
 
 func (r *repository[T, TT]) Get(ctx context.Context, opts *query.QueryOptions) (*PaginationResult[T], error) {
@@ -63,113 +108,211 @@ func (r *repository[T, TT]) Get(ctx context.Context, opts *query.QueryOptions) (
 			Limit: 100,
 		}
 	}
-	q := r.Db.Collection(r.Collection).Limit(opts.Limit)
+	cursor, backwards, err := r.opts.pageCursor(r.Ressource, opts)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := projection[T](r.Ressource, opts)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealQuery[T](r.opts, r.Ressource, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	isFirstPage := true
-	if opts.Next != "" {
-		isFirstPage = false
-		doc, err := r.Db.Collection(r.Collection).Doc(opts.Next).Get(ctx)
-		if err != nil {
-			return nil, err
-		}
-		q = q.StartAfter(doc)
+	// The document ID breaks ties between equal order values, so cursors are unambiguous.
+	orderBy, direction := pageOrder(opts)
+	fsDirection := firestore.Asc
+	if direction == query.Desc {
+		fsDirection = firestore.Desc
 	}
-	if opts.Previous != "" {
-		isFirstPage = false
-		doc, err := r.Db.Collection(r.Collection).Doc(opts.Previous).Get(ctx)
-		if err != nil {
-			return nil, err
-		}
-		q = q.EndBefore(doc)
+	q := r.where(r.query(), sealed)
+	if paths != nil {
+		q = q.Select(paths...)
 	}
+	if orderBy != "id" {
+		q = q.OrderBy(orderBy, fsDirection)
+	}
+	q = q.OrderBy(firestore.DocumentID, fsDirection)
 
-	if opts.OrderBy != "" {
-		direction := firestore.Asc
-		if opts.OrderByDirection == query.Desc {
-			direction = firestore.Desc
+	// One document more than requested tells whether another page follows.
+	if cursor != nil {
+		values := []interface{}{r.pageDoc(cursor.ID)}
+		if orderBy != "id" {
+			values = []interface{}{cursor.value, values[0]}
 		}
-		if opts.OrderBy == "id" {
-			q = q.OrderBy(firestore.DocumentID, direction)
+		if backwards {
+			q = q.EndBefore(values...)
 		} else {
-			q = q.OrderBy(opts.OrderBy, direction)
+			q = q.StartAfter(values...)
 		}
 	}
-
-	for _, f := range opts.Filters {
-		if f.Field == "id" {
-			docRef := r.Db.Collection(r.Collection).Doc(f.Value.(string))
-			q = q.Where(firestore.DocumentID, "==", docRef)
+	if opts.Limit > 0 {
+		if backwards {
+			q = q.LimitToLast(opts.Limit + 1)
 		} else {
-			q = q.Where(f.Field, f.Operator.ToFireStoreOperator(), f.Value)
+			q = q.Limit(opts.Limit + 1)
 		}
 	}
 
-	page := q.Documents(ctx)
-	docs, err := page.GetAll()
+	docs, err := r.getAll(ctx, q)
 	if err != nil {
-		return nil, err
+		return nil, r.translateError("", err)
 	}
 
-	var nextPageKey string
-	if len(docs) >= opts.Limit && len(docs) > 0 {
-		nextPageKey = docs[len(docs)-1].Ref.ID
-	} else {
-		nextPageKey = ""
+	more := opts.Limit > 0 && len(docs) > opts.Limit
+	if more && backwards {
+		docs = docs[1:]
+	} else if more {
+		docs = docs[:opts.Limit]
+	}
+	hasNext, hasPrev := more, cursor != nil
+	if backwards {
+		hasNext, hasPrev = true, more
 	}
 
-	var prevPageKey string
+	var first, last *pageCursor
 	if len(docs) > 0 {
-		if !isFirstPage {
-			prevPageKey = docs[0].Ref.ID
+		if first, err = r.docCursor(opts, docs[0]); err != nil {
+			return nil, err
+		}
+		if last, err = r.docCursor(opts, docs[len(docs)-1]); err != nil {
+			return nil, err
 		}
-	} else {
-		prevPageKey = ""
 	}
+	nextPageKey, prevPageKey := r.opts.pageTokens(first, last, hasNext, hasPrev)
 
 	objs := make([]T, 0)
 	for _, doc := range docs {
-		obj := new(T)
-		if err := (*doc).DataTo(obj); err != nil {
+		obj, err := r.decode(ctx, doc)
+		if err != nil {
 			return nil, err
 		}
-		(*obj).SetDocId((*doc).Ref.ID)
 		objs = append(objs, *obj)
 	}
 
+	count, err := total(ctx, opts, r.Count)
+	if err != nil {
+		return nil, err
+	}
+
 	return &PaginationResult[T]{
 		Items:   objs,
 		Limit:   opts.Limit,
 		Next:    nextPageKey,
 		Prev:    prevPageKey,
 		Filters: &opts.Filters,
+		Total:   count,
+		HasNext: nextPageKey != "",
+		HasPrev: prevPageKey != "",
 	}, nil
 }
 
+// docCursor returns the cursor of doc in the order of opts.
+func (r *repository[T, TT]) docCursor(opts *query.QueryOptions, doc *firestore.DocumentSnapshot) (*pageCursor, error) {
+	var value reflect.Value
+	if orderBy, _ := pageOrder(opts); orderBy != "id" {
+		v, err := doc.DataAt(orderBy)
+		if err != nil {
+			return nil, err
+		}
+		value = reflect.ValueOf(v)
+	}
+	return newPageCursor(opts, r.pageKey(doc.Ref), value)
+}
+
+// filter applies the order, the soft delete condition and the filters of opts to q.
+func (r *repository[T, TT]) filter(q firestore.Query, opts *query.QueryOptions) firestore.Query {
+	if opts.OrderBy != "" {
+		direction := firestore.Asc
+		if opts.OrderByDirection == query.Desc {
+			direction = firestore.Desc
+		}
+		if opts.OrderBy == "id" {
+			q = q.OrderBy(firestore.DocumentID, direction)
+		} else {
+			q = q.OrderBy(opts.OrderBy, direction)
+		}
+	}
+	return r.where(q, opts)
+}
+
+// where applies the soft delete condition and the filters of opts to q.
+func (r *repository[T, TT]) where(q firestore.Query, opts *query.QueryOptions) firestore.Query {
+	if r.opts.softDelete && !opts.IncludeDeleted {
+		q = q.Where(r.opts.softDeleteField, "==", nil)
+	}
+
+	for _, f := range opts.Filters {
+		if f.Field == "id" {
+			q = q.Where(firestore.DocumentID, "==", r.pageDoc(f.Value.(string)))
+		} else {
+			q = q.Where(f.Field, f.Operator.ToFireStoreOperator(), f.Value)
+		}
+	}
+	return q
+}
+
+// All iterates over every document matching opts, fetching pages of opts.Limit lazily.
+// Iteration stops at the first error, which is yielded with a zero value.
+func (r *repository[T, TT]) All(ctx context.Context, opts *query.QueryOptions) iter.Seq2[T, error] {
+	return allPages(ctx, r.Get, opts)
+}
+
 func (r *repository[T, TT]) GetByID(ctx context.Context, id string) (*T, error) {
 	if id == "" {
 		return nil, fmt.Errorf("id is required")
 	}
 
-	doc, err := r.Db.Collection(r.Collection).Doc(id).Get(ctx)
+	doc, err := r.getDoc(ctx, r.collection().Doc(id))
 	if err != nil {
-		return nil, err
+		return nil, r.translateError(id, err)
+	}
+	if r.isDeleted(doc) {
+		return nil, r.notFound(id)
 	}
 
+	return r.decode(ctx, doc)
+}
+
+// decode converts a snapshot into an entity with its document ID and version applied and runs AfterLoad.
+func (r *repository[T, TT]) decode(ctx context.Context, doc *firestore.DocumentSnapshot) (*T, error) {
 	obj := new(T)
 	if err := (*doc).DataTo(obj); err != nil {
 		return nil, err
 	}
+	if err := openEntity(r.opts, *obj); err != nil {
+		return nil, err
+	}
 	(*obj).SetDocId((*doc).Ref.ID)
+	if p, ok := any(*obj).(ParentAware); ok && doc.Ref.Parent.Parent != nil {
+		p.SetParentId(doc.Ref.Parent.Parent.ID)
+	}
+	if version, ok := entityVersion(*obj); ok {
+		setDocVersion(*obj, strconv.FormatInt(version, 10))
+	} else {
+		setDocVersion(*obj, doc.UpdateTime.UTC().Format(time.RFC3339Nano))
+	}
+	if err := afterLoad(ctx, *obj); err != nil {
+		return nil, err
+	}
 
 	return obj, nil
 }
 
 func (r *repository[T, TT]) CreateQueryNotExists(ctx context.Context, obj T, funcQuery func(firestore.Query) firestore.Query) (*string, error) {
 	var docID string
-	err := r.Db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
-		query := r.Db.Collection(r.Collection).Query
+	err := r.runTransaction(ctx, func(ctx context.Context, tx *txn) error {
+		if err := beforeCreate(ctx, obj); err != nil {
+			return err
+		}
+		if err := r.opts.validate(obj); err != nil {
+			return err
+		}
+		query := r.uniqueQuery()
 		query = funcQuery(query)
-		documents, err := query.Documents(ctx).GetAll()
+		documents, err := tx.Documents(query).GetAll()
 		if err != nil {
 			return err
 		}
@@ -184,23 +327,11 @@ func (r *repository[T, TT]) CreateQueryNotExists(ctx context.Context, obj T, fun
 			}
 		}
 
-		val := reflect.ValueOf(obj).Elem()
-		if val.Kind() == reflect.Struct {
-			for _, fieldName := range []string{"CreatedAt", "UpdatedAt"} {
-				field := val.FieldByName(fieldName)
-				if field.IsValid() && field.CanSet() && field.Type() == reflect.TypeOf(time.Now()) {
-					field.Set(reflect.ValueOf(time.Now()))
-				}
-			}
-		}
-
-		docRef := r.Db.Collection(r.Collection).NewDoc()
-		tx.Set(docRef, obj)
-		docID = docRef.ID
-		return nil
+		docID, err = r.createInTx(ctx, tx, obj)
+		return err
 	})
 	if err != nil {
-		return nil, err
+		return nil, r.translateError("", err)
 	}
 
 	return &docID, nil
@@ -208,65 +339,114 @@ func (r *repository[T, TT]) CreateQueryNotExists(ctx context.Context, obj T, fun
 
 func (r *repository[T, TT]) Create(ctx context.Context, obj T) (*string, error) {
 	var docID string
-	err := r.Db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
-		query := r.Db.Collection(r.Collection).Query
-		for field, value := range obj.UniqFields() {
-			query = query.Where(field, "==", value)
+	err := r.runTransaction(ctx, func(ctx context.Context, tx *txn) error {
+		if err := beforeCreate(ctx, obj); err != nil {
+			return err
 		}
-		documents, err := query.Documents(ctx).GetAll()
-		if err != nil {
+		if err := r.opts.validate(obj); err != nil {
 			return err
 		}
-		if len(documents) > 0 {
-			return &errors.ErrorAlreadyExists{
-				ErrorDetail: errors.ErrorDetail{
-					Resource: r.Ressource,
-					Field:    "Reference",
-					Value:    "",
-					Message:  r.Ressource + " with Reference already exists",
-				},
+		if !r.opts.uniqueConstraints {
+			documents, err := r.uniqueMatches(tx, obj.UniqFields())
+			if err != nil {
+				return err
 			}
-		}
-
-		val := reflect.ValueOf(obj).Elem()
-		if val.Kind() == reflect.Struct {
-			for _, fieldName := range []string{"CreatedAt", "UpdatedAt"} {
-				field := val.FieldByName(fieldName)
-				if field.IsValid() && field.CanSet() && field.Type() == reflect.TypeOf(time.Now()) {
-					field.Set(reflect.ValueOf(time.Now()))
-				}
+			if len(documents) > 0 {
+				return alreadyExists(r.Ressource, obj.UniqFields())
 			}
 		}
 
-		docRef := r.Db.Collection(r.Collection).NewDoc()
-		tx.Set(docRef, obj)
-		docID = docRef.ID
-		return nil
+		var err error
+		docID, err = r.createInTx(ctx, tx, obj)
+		return err
 	})
 	if err != nil {
-		return nil, err
+		return nil, r.translateError("", err)
 	}
 
 	return &docID, nil
 }
 
+// createInTx writes obj as a new document, reserving its unique values with WithUniqueConstraints.
+func (r *repository[T, TT]) createInTx(ctx context.Context, tx *txn, obj T) (string, error) {
+	prepareCreate(obj)
+
+	docRef := r.collection().NewDoc()
+	commit := func() error { return nil }
+	if r.opts.uniqueConstraints {
+		var err error
+		if commit, err = r.reserve(tx, docRef.ID, nil, obj.UniqFields()); err != nil {
+			return "", err
+		}
+	}
+	stored, err := sealEntity(r.opts, obj)
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Set(docRef, stored); err != nil {
+		return "", err
+	}
+	if r.opts.softDelete {
+		if err := tx.Update(docRef, r.notDeletedUpdate()); err != nil {
+			return "", err
+		}
+	}
+	if err := r.audit(ctx, tx, docRef.ID, AuditCreate, createdChanges(stored)); err != nil {
+		return "", err
+	}
+	if err := commit(); err != nil {
+		return "", err
+	}
+	obj.SetDocId(docRef.ID)
+	return docRef.ID, afterCreate(ctx, obj)
+}
+
+// CreateEasy writes obj without a transaction, so an AfterCreate error is returned but cannot
+// undo the write.
 func (r *repository[T, TT]) CreateEasy(ctx context.Context, obj T) (*string, error) {
 	var docID string
-	val := reflect.ValueOf(obj).Elem()
-	if val.Kind() == reflect.Struct {
-		for _, fieldName := range []string{"CreatedAt", "UpdatedAt"} {
-			field := val.FieldByName(fieldName)
-			if field.IsValid() && field.CanSet() && field.Type() == reflect.TypeOf(time.Now()) {
-				field.Set(reflect.ValueOf(time.Now()))
+	if err := beforeCreate(ctx, obj); err != nil {
+		return nil, err
+	}
+	if err := r.opts.validate(obj); err != nil {
+		return nil, err
+	}
+	prepareCreate(obj)
+	stored, err := sealEntity(r.opts, obj)
+	if err != nil {
+		return nil, err
+	}
+	docRef := r.collection().NewDoc()
+	if inTransaction(ctx) {
+		err = r.runTransaction(ctx, func(ctx context.Context, tx *txn) error {
+			if err := tx.Set(docRef, stored); err != nil {
+				return err
 			}
+			if r.opts.softDelete {
+				if err := tx.Update(docRef, r.notDeletedUpdate()); err != nil {
+					return err
+				}
+			}
+			return r.audit(ctx, tx, docRef.ID, AuditCreate, createdChanges(stored))
+		})
+	} else {
+		batch := r.Db.Batch().Set(docRef, stored)
+		if r.opts.softDelete {
+			batch = batch.Update(docRef, r.notDeletedUpdate())
+		}
+		if r.opts.audit {
+			batch = batch.Create(r.history(docRef.ID).collection().NewDoc(), newAuditRecord(ctx, docRef.ID, AuditCreate, createdChanges(stored)))
 		}
+		_, err = batch.Commit(ctx)
 	}
-	docRef := r.Db.Collection(r.Collection).NewDoc()
-	_, err := docRef.Set(ctx, obj)
 	if err != nil {
-		return nil, err
+		return nil, r.translateError(docRef.ID, err)
 	}
 	docID = docRef.ID
+	obj.SetDocId(docID)
+	if err := afterCreate(ctx, obj); err != nil {
+		return &docID, err
+	}
 
 	return &docID, nil
 }
@@ -276,6 +456,118 @@ func (r *repository[T, TT]) Update(ctx context.Context, id string, data map[stri
 		return fmt.Errorf("id is required")
 	}
 
+	docRef := r.collection().Doc(id)
+	if !r.updateNeedsTx(data) && !inTransaction(ctx) {
+		sealed, err := sealData[T](r.opts, data)
+		if err != nil {
+			return err
+		}
+		_, err = docRef.Update(ctx, r.updates(sealed))
+		if err != nil {
+			return r.translateError(id, err)
+		}
+		return nil
+	}
+
+	err := r.runTransaction(ctx, func(ctx context.Context, tx *txn) error {
+		doc, err := tx.Get(docRef)
+		if err != nil {
+			return err
+		}
+		changes, commit, err := r.prepareUpdate(ctx, tx, doc, data)
+		if err != nil {
+			return err
+		}
+		if err := tx.Update(docRef, r.updates(changes)); err != nil {
+			return err
+		}
+		if err := r.audit(ctx, tx, id, AuditUpdate, updatedChanges(changes, snapshotValue(doc))); err != nil {
+			return err
+		}
+		return commit()
+	})
+	return r.translateError(id, err)
+}
+
+// updateNeedsTx reports whether an update of data must read the document first,
+// to run BeforeUpdate, to check unique fields or to record the previous values.
+func (r *repository[T, TT]) updateNeedsTx(data map[string]interface{}) bool {
+	return r.opts.audit || implements[BeforeUpdater, T]() || touchesUnique(newEntity[T]().UniqFields(), data)
+}
+
+// prepareUpdate runs BeforeUpdate on the stored entity and checks the unique fields touched by
+// the resulting changes, which are returned as stored, with encrypted fields encrypted. The
+// returned function moves reservations and must run after all reads.
+func (r *repository[T, TT]) prepareUpdate(ctx context.Context, tx *txn, doc *firestore.DocumentSnapshot, data map[string]interface{}) (map[string]interface{}, func() error, error) {
+	changes := data
+	if implements[BeforeUpdater, T]() {
+		obj, err := r.decode(ctx, doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		changes = maps.Clone(data)
+		if err := beforeUpdate(ctx, *obj, changes); err != nil {
+			return nil, nil, err
+		}
+	}
+	commit := func() error { return nil }
+	if touchesUnique(newEntity[T]().UniqFields(), changes) {
+		var err error
+		if commit, err = r.checkUniqueUpdate(ctx, tx, doc, changes); err != nil {
+			return nil, nil, err
+		}
+	}
+	sealed, err := sealData[T](r.opts, changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sealed, commit, nil
+}
+
+// checkUniqueUpdate fails if another document holds the unique values doc would have after
+// data is applied. The returned function moves the reservations and must run after all reads.
+func (r *repository[T, TT]) checkUniqueUpdate(ctx context.Context, tx *txn, doc *firestore.DocumentSnapshot, data map[string]interface{}) (func() error, error) {
+	noop := func() error { return nil }
+	if r.opts.excludesDeletedFromUnique() && r.isDeleted(doc) {
+		return noop, nil
+	}
+	obj, err := r.decode(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	before := (*obj).UniqFields()
+	uniq, err := updatedUniqFields(*obj, data)
+	if err != nil {
+		return nil, err
+	}
+	if r.opts.uniqueConstraints {
+		return r.reserve(tx, doc.Ref.ID, before, uniq)
+	}
+
+	documents, err := r.uniqueMatches(tx, uniq)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range documents {
+		if other.Ref.ID != doc.Ref.ID {
+			return nil, alreadyExists(r.Ressource, uniq)
+		}
+	}
+	return noop, nil
+}
+
+// updates converts data into Firestore updates, incrementing the Version field unless data sets it.
+func (r *repository[T, TT]) updates(data map[string]interface{}) []firestore.Update {
+	updates := fieldUpdates(data)
+	if vf, ok := versionField(reflect.TypeOf((*T)(nil)).Elem()); ok {
+		if _, set := data[vf.Name]; !set {
+			updates = append(updates, firestore.Update{Path: vf.Name, Value: firestore.FieldTransformIncrement(1)})
+		}
+	}
+	return updates
+}
+
+func fieldUpdates(data map[string]interface{}) []firestore.Update {
 	updates := []firestore.Update{}
 	for k, v := range data {
 		updates = append(updates, firestore.Update{
@@ -283,21 +575,467 @@ func (r *repository[T, TT]) Update(ctx context.Context, id string, data map[stri
 			Value: v,
 		})
 	}
-	_, err := r.Db.Collection(r.Collection).Doc(id).Update(ctx, updates)
+	return updates
+}
+
+// Patch updates the fields of patch named by mask, a list of Go field names. patch may be a
+// partial T or any struct whose fields match T's. Unknown fields fail with errors.ErrorBadRequest.
+func (r *repository[T, TT]) Patch(ctx context.Context, id string, patch interface{}, mask ...string) error {
+	data, err := patchData(r.Ressource, reflect.TypeOf((*T)(nil)).Elem(), patch, mask)
 	if err != nil {
 		return err
 	}
-	return nil
+	if err := r.opts.validatePatch(patch, mask); err != nil {
+		return err
+	}
+	return r.Update(ctx, id, data)
 }
 
-func (r *repository[T, TT]) Delete(ctx context.Context, id string) error {
+// UpdateWithVersion applies data only if the document still has the given version.
+// Entities with an integer Version field are compared and incremented in a transaction,
+// all others use the Firestore update time as precondition.
+func (r *repository[T, TT]) UpdateWithVersion(ctx context.Context, id string, version string, data map[string]interface{}) error {
 	if id == "" {
 		return fmt.Errorf("id is required")
 	}
 
-	_, err := r.Db.Collection(r.Collection).Doc(id).Delete(ctx)
+	docRef := r.collection().Doc(id)
+	if vf, ok := versionField(reflect.TypeOf((*T)(nil)).Elem()); ok {
+		expected, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return preconditionFailed(r.Ressource, id, version)
+		}
+		err = r.runTransaction(ctx, func(ctx context.Context, tx *txn) error {
+			doc, err := tx.Get(docRef)
+			if err != nil {
+				return err
+			}
+			current, err := doc.DataAt(vf.Name)
+			if err != nil {
+				current = int64(0)
+			}
+			if n, ok := current.(int64); !ok || n != expected {
+				return preconditionFailed(r.Ressource, id, version)
+			}
+			changes, commit, err := r.prepareUpdate(ctx, tx, doc, data)
+			if err != nil {
+				return err
+			}
+			updates := append(fieldUpdates(changes), firestore.Update{Path: vf.Name, Value: expected + 1})
+			if err := tx.Update(docRef, updates); err != nil {
+				return err
+			}
+			if err := r.audit(ctx, tx, id, AuditUpdate, updatedChanges(changes, snapshotValue(doc))); err != nil {
+				return err
+			}
+			return commit()
+		})
+		return r.translateError(id, err)
+	}
+
+	updateTime, err := time.Parse(time.RFC3339Nano, version)
+	if err != nil {
+		return preconditionFailed(r.Ressource, id, version)
+	}
+	if r.updateNeedsTx(data) || inTransaction(ctx) {
+		err = r.runTransaction(ctx, func(ctx context.Context, tx *txn) error {
+			doc, err := tx.Get(docRef)
+			if err != nil {
+				return err
+			}
+			if !doc.UpdateTime.Equal(updateTime) {
+				return preconditionFailed(r.Ressource, id, version)
+			}
+			changes, commit, err := r.prepareUpdate(ctx, tx, doc, data)
+			if err != nil {
+				return err
+			}
+			if err := tx.Update(docRef, fieldUpdates(changes)); err != nil {
+				return err
+			}
+			if err := r.audit(ctx, tx, id, AuditUpdate, updatedChanges(changes, snapshotValue(doc))); err != nil {
+				return err
+			}
+			return commit()
+		})
+		return r.translateError(id, err)
+	}
+	sealed, err := sealData[T](r.opts, data)
 	if err != nil {
 		return err
 	}
+	_, err = docRef.Update(ctx, fieldUpdates(sealed), firestore.LastUpdateTime(updateTime))
+	if status.Code(err) == codes.FailedPrecondition {
+		return preconditionFailed(r.Ressource, id, version)
+	}
+	return r.translateError(id, err)
+}
+
+func (r *repository[T, TT]) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	if r.opts.softDelete {
+		deletedAt := time.Now()
+		deleted := []firestore.Update{{Path: r.opts.softDeleteField, Value: deletedAt}}
+		release := r.opts.uniqueConstraints && r.opts.uniqueIgnoresDeleted
+		if release || r.opts.audit || implements[BeforeDeleter, T]() || inTransaction(ctx) {
+			return r.deleteInTx(ctx, id, release, func(tx *txn, docRef *firestore.DocumentRef, doc *firestore.DocumentSnapshot) error {
+				if err := tx.Update(docRef, deleted); err != nil {
+					return err
+				}
+				changes := updatedChanges(map[string]interface{}{r.opts.softDeleteField: deletedAt}, snapshotValue(doc))
+				return r.audit(ctx, tx, id, AuditDelete, changes)
+			})
+		}
+		_, err := r.collection().Doc(id).Update(ctx, deleted)
+		return r.translateError(id, err)
+	}
+	return r.Purge(ctx, id)
+}
+
+func (r *repository[T, TT]) Restore(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+	if !r.opts.softDelete {
+		return fmt.Errorf("soft delete is not enabled for %s", r.Ressource)
+	}
+
+	err := r.runTransaction(ctx, func(ctx context.Context, tx *txn) error {
+		docRef := r.collection().Doc(id)
+		doc, err := tx.Get(docRef)
+		if err != nil {
+			return err
+		}
+		commit := func() error { return nil }
+		if r.opts.excludesDeletedFromUnique() && r.isDeleted(doc) {
+			obj, err := r.decode(ctx, doc)
+			if err != nil {
+				return err
+			}
+			if r.opts.uniqueConstraints {
+				if commit, err = r.reserve(tx, id, nil, (*obj).UniqFields()); err != nil {
+					return err
+				}
+			} else {
+				documents, err := r.uniqueMatches(tx, (*obj).UniqFields())
+				if err != nil {
+					return err
+				}
+				if len(documents) > 0 {
+					return alreadyExists(r.Ressource, (*obj).UniqFields())
+				}
+			}
+		}
+		if err := tx.Update(docRef, r.notDeletedUpdate()); err != nil {
+			return err
+		}
+		changes := updatedChanges(map[string]interface{}{r.opts.softDeleteField: nil}, snapshotValue(doc))
+		if err := r.audit(ctx, tx, id, AuditRestore, changes); err != nil {
+			return err
+		}
+		return commit()
+	})
+	return r.translateError(id, err)
+}
+
+func (r *repository[T, TT]) Purge(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	if r.opts.uniqueConstraints || r.opts.audit || implements[BeforeDeleter, T]() || inTransaction(ctx) {
+		return r.deleteInTx(ctx, id, r.opts.uniqueConstraints, func(tx *txn, docRef *firestore.DocumentRef, doc *firestore.DocumentSnapshot) error {
+			if err := tx.Delete(docRef); err != nil {
+				return err
+			}
+			return r.audit(ctx, tx, id, AuditPurge, removedChanges(doc.Data()))
+		})
+	}
+	// Without the precondition Firestore reports success for documents that do not exist.
+	_, err := r.collection().Doc(id).Delete(ctx, firestore.Exists)
+	if err != nil {
+		return r.translateError(id, err)
+	}
 	return nil
 }
+
+// deleteInTx runs write in a transaction after BeforeDelete, releasing the unique values
+// reserved by the document if release is set. A missing document fails with ErrorNotFound.
+func (r *repository[T, TT]) deleteInTx(ctx context.Context, id string, release bool, write func(tx *txn, docRef *firestore.DocumentRef, doc *firestore.DocumentSnapshot) error) error {
+	err := r.runTransaction(ctx, func(ctx context.Context, tx *txn) error {
+		docRef := r.collection().Doc(id)
+		doc, err := tx.Get(docRef)
+		if err != nil {
+			return err
+		}
+		obj, err := r.decode(ctx, doc)
+		if err != nil {
+			return err
+		}
+		if err := beforeDelete(ctx, *obj); err != nil {
+			return err
+		}
+
+		commit := func() error { return nil }
+		// Tombstones ignored for uniqueness released their values when they were deleted.
+		if release && !(r.opts.excludesDeletedFromUnique() && r.isDeleted(doc)) {
+			if commit, err = r.reserve(tx, id, (*obj).UniqFields(), nil); err != nil {
+				return err
+			}
+		}
+		if err := write(tx, docRef, doc); err != nil {
+			return err
+		}
+		return commit()
+	})
+	return r.translateError(id, err)
+}
+
+// CreateMany stores objs with a BulkWriter. Like CreateEasy it does not check UniqFields.
+func (r *repository[T, TT]) CreateMany(ctx context.Context, objs []T) ([]BulkResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Audit records must be written atomically with their document, so each entity gets its own batch.
+	// In a unit of work every entity is written by the transaction.
+	if r.opts.audit || inTransaction(ctx) {
+		return bulkEach(len(objs), r.bulkConcurrency(ctx), func(i int) (string, error) {
+			docID, err := r.CreateEasy(ctx, objs[i])
+			if docID == nil {
+				return "", err
+			}
+			return *docID, err
+		}), nil
+	}
+
+	results := make([]BulkResult, len(objs))
+	refs := make([]*firestore.DocumentRef, len(objs))
+	r.bulkWrite(ctx, results, allIndexes(len(objs)), func(bw *firestore.BulkWriter, i int) (*firestore.BulkWriterJob, error) {
+		if err := beforeCreate(ctx, objs[i]); err != nil {
+			return nil, err
+		}
+		if err := r.opts.validate(objs[i]); err != nil {
+			return nil, err
+		}
+		prepareCreate(objs[i])
+		stored, err := sealEntity(r.opts, objs[i])
+		if err != nil {
+			return nil, err
+		}
+		refs[i] = r.collection().NewDoc()
+		results[i].ID = refs[i].ID
+		return bw.Create(refs[i], stored)
+	})
+
+	// A BulkWriter rejects two writes to the same document in one batch,
+	// so the explicit null deletion timestamp is written in a second pass.
+	// Documents without it would be hidden from queries, so they are removed again.
+	if r.opts.softDelete {
+		created := make([]int, 0, len(objs))
+		for i := range results {
+			if results[i].Err == nil {
+				created = append(created, i)
+			}
+		}
+		r.bulkWrite(ctx, results, created, func(bw *firestore.BulkWriter, i int) (*firestore.BulkWriterJob, error) {
+			return bw.Update(refs[i], r.notDeletedUpdate())
+		})
+		var failed []int
+		for _, i := range created {
+			if results[i].Err != nil {
+				failed = append(failed, i)
+			}
+		}
+		rollback := make([]BulkResult, len(results))
+		r.bulkWrite(ctx, rollback, failed, func(bw *firestore.BulkWriter, i int) (*firestore.BulkWriterJob, error) {
+			return bw.Delete(refs[i])
+		})
+		for _, i := range failed {
+			if rollback[i].Err != nil {
+				results[i].Err = fmt.Errorf("%w; removing the incomplete document %s failed: %v", results[i].Err, refs[i].ID, rollback[i].Err)
+			}
+		}
+	}
+
+	for i := range results {
+		if results[i].Err == nil {
+			objs[i].SetDocId(results[i].ID)
+			results[i].Err = afterCreate(ctx, objs[i])
+		}
+	}
+	return results, nil
+}
+
+// UpdateMany applies every update with a BulkWriter and stamps UpdatedAt like Create does.
+func (r *repository[T, TT]) UpdateMany(ctx context.Context, updates []BulkUpdate) ([]BulkResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	results := make([]BulkResult, len(updates))
+
+	// Audited updates, updates running hooks and those touching unique fields need the transaction of
+	// Update and bypass the BulkWriter.
+	var bulk, checked []int
+	for i := range updates {
+		if r.updateNeedsTx(updates[i].Data) || inTransaction(ctx) {
+			checked = append(checked, i)
+		} else {
+			bulk = append(bulk, i)
+		}
+	}
+	runConcurrent(len(checked), r.bulkConcurrency(ctx), func(c int) {
+		i := checked[c]
+		results[i] = BulkResult{ID: updates[i].ID, Err: r.Update(ctx, updates[i].ID, stampUpdatedAt(t, updates[i].Data))}
+	})
+	r.bulkWrite(ctx, results, bulk, func(bw *firestore.BulkWriter, i int) (*firestore.BulkWriterJob, error) {
+		results[i].ID = updates[i].ID
+		if updates[i].ID == "" {
+			return nil, errIDRequired
+		}
+		data, err := sealData[T](r.opts, stampUpdatedAt(t, updates[i].Data))
+		if err != nil {
+			return nil, err
+		}
+		return bw.Update(r.collection().Doc(updates[i].ID), r.updates(data))
+	})
+	return results, nil
+}
+
+// DeleteMany deletes, or soft deletes, every id with a BulkWriter.
+func (r *repository[T, TT]) DeleteMany(ctx context.Context, ids []string) ([]BulkResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Reservations, audit records and BeforeDelete need a transaction per document.
+	if r.opts.uniqueConstraints || r.opts.audit || implements[BeforeDeleter, T]() || inTransaction(ctx) {
+		return bulkEach(len(ids), r.bulkConcurrency(ctx), func(i int) (string, error) {
+			return ids[i], r.Delete(ctx, ids[i])
+		}), nil
+	}
+
+	results := make([]BulkResult, len(ids))
+	now := time.Now()
+	r.bulkWrite(ctx, results, allIndexes(len(ids)), func(bw *firestore.BulkWriter, i int) (*firestore.BulkWriterJob, error) {
+		results[i].ID = ids[i]
+		if ids[i] == "" {
+			return nil, errIDRequired
+		}
+		docRef := r.collection().Doc(ids[i])
+		if r.opts.softDelete {
+			return bw.Update(docRef, []firestore.Update{{Path: r.opts.softDeleteField, Value: now}})
+		}
+		return bw.Delete(docRef, firestore.Exists)
+	})
+	return results, nil
+}
+
+// bulkWrite splits indexes across the configured number of BulkWriters and records the
+// outcome of every enqueued write in results.
+func (r *repository[T, TT]) bulkWrite(ctx context.Context, results []BulkResult, indexes []int, enqueue func(bw *firestore.BulkWriter, i int) (*firestore.BulkWriterJob, error)) {
+	chunks := partition(indexes, r.opts.bulkConcurrency)
+	runConcurrent(len(chunks), r.opts.bulkConcurrency, func(c int) {
+		bw := r.Db.BulkWriter(ctx)
+		jobs := make(map[int]*firestore.BulkWriterJob, len(chunks[c]))
+		for _, i := range chunks[c] {
+			job, err := enqueue(bw, i)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			jobs[i] = job
+		}
+		bw.End()
+		for i, job := range jobs {
+			if _, err := job.Results(); err != nil {
+				results[i].Err = r.translateError(results[i].ID, err)
+			}
+		}
+	})
+}
+
+// uniqueMatches returns the documents holding all values of uniq.
+func (r *repository[T, TT]) uniqueMatches(tx *txn, uniq map[string]interface{}) ([]*firestore.DocumentSnapshot, error) {
+	filters, err := uniqueFilters[T](r.opts, uniq)
+	if err != nil {
+		return nil, err
+	}
+	query := r.uniqueQuery()
+	for _, filter := range filters {
+		query = query.Where(filter.Field, filter.Operator.ToFireStoreOperator(), filter.Value)
+	}
+	return tx.Documents(query).GetAll()
+}
+
+// uniqueQuery is the base query for uniqueness checks, skipping soft deleted documents if configured.
+func (r *repository[T, TT]) uniqueQuery() firestore.Query {
+	query := r.collection().Query
+	if r.opts.excludesDeletedFromUnique() {
+		query = query.Where(r.opts.softDeleteField, "==", nil)
+	}
+	return query
+}
+
+// notDeletedUpdate writes an explicit null deletion timestamp, which Firestore needs to match "== nil" filters.
+func (r *repository[T, TT]) notDeletedUpdate() []firestore.Update {
+	return []firestore.Update{{Path: r.opts.softDeleteField, Value: nil}}
+}
+
+func (r *repository[T, TT]) isDeleted(doc *firestore.DocumentSnapshot) bool {
+	if !r.opts.softDelete {
+		return false
+	}
+	value, err := doc.DataAt(r.opts.softDeleteField)
+	return err == nil && value != nil
+}
+
+// translateError converts Firestore status errors into the toolkit's typed errors for the document id.
+// Collection level operations pass an empty id; their FailedPrecondition usually means a missing index
+// and is returned unchanged.
+func (r *repository[T, TT]) translateError(id string, err error) error {
+	if err == nil {
+		return nil
+	}
+	detail := errors.ErrorDetail{
+		Resource: r.Ressource,
+		Field:    "id",
+		Value:    id,
+	}
+	switch status.Code(err) {
+	case codes.NotFound:
+		return r.notFound(id)
+	case codes.AlreadyExists:
+		detail.Message = r.Ressource + " with id " + id + " already exists"
+		return &errors.ErrorAlreadyExists{ErrorDetail: detail}
+	case codes.PermissionDenied:
+		detail.Message = "access to " + r.Ressource + " denied"
+		return &errors.ErrorForbidden{ErrorDetail: detail}
+	case codes.FailedPrecondition:
+		if id == "" {
+			return err
+		}
+		detail.Message = r.Ressource + " with id " + id + " does not meet the precondition"
+		return &errors.ErrorPreconditionFailed{ErrorDetail: detail}
+	case codes.Aborted:
+		detail.Message = r.Ressource + " was modified by a concurrent transaction"
+		return &errors.ErrorConflict{ErrorDetail: detail}
+	}
+	return err
+}
+
+func (r *repository[T, TT]) notFound(id string) error {
+	return &errors.ErrorNotFound{
+		ErrorDetail: errors.ErrorDetail{
+			Resource: r.Ressource,
+			Field:    "id",
+			Value:    id,
+			Message:  r.Ressource + " with id " + id + " not found",
+		},
+	}
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTranslateError(t *testing.T) {
+	r := &repository[*testUser, testUser]{Ressource: "User"}
+	other := fmt.Errorf("boom")
+
+	tests := []struct {
+		name       string
+		id         string
+		err        error
+		wantStatus int
+		wantSame   bool
+	}{
+		{"not found", "abc", status.Error(codes.NotFound, "no document"), 404, false},
+		{"already exists", "abc", status.Error(codes.AlreadyExists, "exists"), 409, false},
+		{"permission denied", "abc", status.Error(codes.PermissionDenied, "denied"), 403, false},
+		{"failed precondition", "abc", status.Error(codes.FailedPrecondition, "update time"), 412, false},
+		{"missing index", "", status.Error(codes.FailedPrecondition, "requires an index"), 500, true},
+		{"aborted", "abc", status.Error(codes.Aborted, "contention"), 409, false},
+		{"typed error", "abc", r.notFound("abc"), 404, true},
+		{"other", "abc", other, 500, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.translateError(tt.id, tt.err)
+			if tt.wantSame && got != tt.err {
+				t.Errorf("Expected error to be returned unchanged, got %v", got)
+			}
+			if _, code := errors.NewErrorResponse(got); code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, code)
+			}
+		})
+	}
+
+	if r.translateError("abc", nil) != nil {
+		t.Errorf("Expected nil to stay nil")
+	}
+	notFound, ok := r.translateError("abc", status.Error(codes.NotFound, "")).(*errors.ErrorNotFound)
+	if !ok || notFound.Resource != "User" || notFound.Value != "abc" {
+		t.Errorf("Expected resource and id in the error, got %+v", notFound)
+	}
+}
+
+func TestPageKey(t *testing.T) {
+	customers := &firestore.CollectionRef{ID: "customers"}
+	customer := &firestore.DocumentRef{Parent: customers, ID: "c1"}
+	order := &firestore.DocumentRef{Parent: &firestore.CollectionRef{Parent: customer, ID: "orders"}, ID: "o1"}
+
+	scoped := &repository[*testUser, testUser]{Parent: customer, Collection: "orders"}
+	if got := scoped.pageKey(order); got != "o1" {
+		t.Errorf("Expected the document ID as page key, got %s", got)
+	}
+	group := &repository[*testUser, testUser]{Collection: "orders", group: true}
+	if got := group.pageKey(order); got != "customers/c1/orders/o1" {
+		t.Errorf("Expected the relative path as collection group page key, got %s", got)
+	}
+	if got := relativePath(customer); got != "customers/c1" {
+		t.Errorf("Expected top level path, got %s", got)
+	}
+}
+
+func TestCollectionName(t *testing.T) {
+	irregular := func(ressource string) string {
+		if ressource == "Category" {
+			return "categories"
+		}
+		return defaultPluralize(ressource)
+	}
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"default", nil, "categorys"},
+		{"pluralizer", []Option{WithPluralizer(irregular)}, "categories"},
+		{"explicit name", []Option{WithCollectionName("product_categories")}, "product_categories"},
+		{"prefix", []Option{WithPluralizer(irregular), WithCollectionPrefix("staging_")}, "staging_categories"},
+		{"suffix", []Option{WithCollectionName("cats"), WithCollectionSuffix("_test")}, "cats_test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newOptions(tt.opts).collection("Category"); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	repo := NewFirebaseRepository[*testUser, testUser](nil, "User", WithCollectionPrefix("test_")).(*repository[*testUser, testUser])
+	if repo.Collection != "test_users" {
+		t.Errorf("Expected prefixed collection, got %s", repo.Collection)
+	}
+}
+
+func TestDeleteMissingDocument(t *testing.T) {
+	ctx := context.Background()
+	repos := map[string]func(t *testing.T, opts ...Option) Repository[*testUser, testUser]{
+		"memory": func(t *testing.T, opts ...Option) Repository[*testUser, testUser] {
+			return NewMemoryRepository[*testUser, testUser]("User", opts...)
+		},
+		"firestore": func(t *testing.T, opts ...Option) Repository[*testUser, testUser] {
+			return emulatorRepository[*testUser, testUser](t, "User", opts...)
+		},
+	}
+	for name, newRepo := range repos {
+		t.Run(name, func(t *testing.T) {
+			for _, opts := range [][]Option{nil, {WithSoftDelete()}, {WithAudit()}} {
+				repo := newRepo(t, opts...)
+				if _, ok := repo.Delete(ctx, "missing").(*errors.ErrorNotFound); !ok {
+					t.Errorf("Expected ErrorNotFound from Delete with %d options", len(opts))
+				}
+				if _, ok := repo.Purge(ctx, "missing").(*errors.ErrorNotFound); !ok {
+					t.Errorf("Expected ErrorNotFound from Purge with %d options", len(opts))
+				}
+			}
+		})
+	}
+}
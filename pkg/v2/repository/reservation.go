@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// reservation claims one unique value for the document Owner.
+type reservation struct {
+	Field string      `firestore:"field"`
+	Value interface{} `firestore:"value"`
+	Owner string      `firestore:"owner"`
+}
+
+// reservationSuffix names the side collection holding the reservations of a collection.
+const reservationSuffix = "_unique"
+
+// reservationRef returns the reservation of value for field. Values in different unique scopes
+// get different reservations; without a scope the key only depends on field and value.
+func (r *repository[T, TT]) reservationRef(field string, value interface{}, scope string) *firestore.DocumentRef {
+	key := field + "\x00" + fmt.Sprint(value)
+	if scope != "" {
+		key += "\x00" + scope
+	}
+	sum := sha256.Sum256([]byte(key))
+	return r.collectionRef(r.Collection + reservationSuffix).Doc(hex.EncodeToString(sum[:]))
+}
+
+// reserve reads the reservations needed to move docID from the unique values before to after.
+// It fails with ErrorAlreadyExists for the first field whose new value belongs to another document.
+// As Firestore transactions must read before they write, the writes are returned as a function
+// the caller runs after its own reads.
+func (r *repository[T, TT]) reserve(tx *txn, docID string, before, after map[string]interface{}) (func() error, error) {
+	var writes []func() error
+	// Reservations are keyed by the stored values, so encrypted values are not revealed. Values
+	// reserved under a retired key are checked and released as well.
+	encrypted, err := r.opts.encryptedFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	oldScope, newScope := r.scopeKey(before), r.scopeKey(after)
+	fields := sortedKeys(after)
+	for _, field := range sortedKeys(before) {
+		if _, ok := after[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	for _, field := range fields {
+		if slices.Contains(r.opts.uniqueScope, field) {
+			continue
+		}
+		oldValue, newValue := before[field], after[field]
+		if !isZeroValue(oldValue) && !isZeroValue(newValue) && fmt.Sprint(oldValue) == fmt.Sprint(newValue) && oldScope == newScope {
+			continue
+		}
+
+		if !isZeroValue(newValue) {
+			stored, err := r.opts.storedValues(encrypted, field, newValue)
+			if err != nil {
+				return nil, err
+			}
+			for _, value := range stored {
+				owner, err := reservationOwner(tx, r.reservationRef(field, value, newScope))
+				if err != nil {
+					return nil, err
+				}
+				if owner != "" && owner != docID {
+					return nil, alreadyExists(r.Ressource, map[string]interface{}{field: newValue})
+				}
+			}
+			ref := r.reservationRef(field, stored[0], newScope)
+			res := reservation{Field: field, Value: stored[0], Owner: docID}
+			writes = append(writes, func() error { return tx.Set(ref, res) })
+		}
+
+		if !isZeroValue(oldValue) {
+			stored, err := r.opts.storedValues(encrypted, field, oldValue)
+			if err != nil {
+				return nil, err
+			}
+			for _, value := range stored {
+				ref := r.reservationRef(field, value, oldScope)
+				owner, err := reservationOwner(tx, ref)
+				if err != nil {
+					return nil, err
+				}
+				if owner == docID {
+					writes = append(writes, func() error { return tx.Delete(ref) })
+				}
+			}
+		}
+	}
+
+	return func() error {
+		for _, write := range writes {
+			if err := write(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// scopeKey joins the unique scope values of uniq in sorted field order.
+func (r *repository[T, TT]) scopeKey(uniq map[string]interface{}) string {
+	scope := r.opts.scopeValues(uniq)
+	values := make([]string, 0, len(scope))
+	for _, field := range sortedKeys(scope) {
+		values = append(values, field+"="+fmt.Sprint(scope[field]))
+	}
+	return strings.Join(values, "\x00")
+}
+
+// reservationOwner returns the document holding a reservation, or "" if the value is free.
+func reservationOwner(tx *txn, ref *firestore.DocumentRef) (string, error) {
+	doc, err := tx.Get(ref)
+	if status.Code(err) == codes.NotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var res reservation
+	if err := doc.DataTo(&res); err != nil {
+		return "", err
+	}
+	return res.Owner, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/firestore"
+)
+
+// backfillPageSize is the number of documents BackfillSoftDelete reads at a time.
+const backfillPageSize = 500
+
+// BackfillSoftDelete writes a null deletion timestamp into every document of a Firestore
+// repository that lacks the soft delete field and returns the number of documents it updated.
+// Firestore's "== null" filter does not match missing fields, so documents written before
+// WithSoftDelete was enabled are hidden from Get, All and Count until they are backfilled.
+// Other repositories treat a missing value as not deleted and fail with ErrNotSupported.
+func BackfillSoftDelete[T Entity, TT any](ctx context.Context, repo Repository[T, TT]) (int, error) {
+	r, ok := repo.(*repository[T, TT])
+	if !ok {
+		return 0, fmt.Errorf("BackfillSoftDelete: %w", ErrNotSupported)
+	}
+	if !r.opts.softDelete {
+		return 0, fmt.Errorf("soft delete is not enabled for %s", r.Ressource)
+	}
+
+	updated := 0
+	q := r.query().Select(r.opts.softDeleteField).OrderBy(firestore.DocumentID, firestore.Asc).Limit(backfillPageSize)
+	for page := q; ; {
+		docs, err := page.Documents(ctx).GetAll()
+		if err != nil {
+			return updated, r.translateError("", err)
+		}
+		var missing []*firestore.DocumentSnapshot
+		for _, doc := range docs {
+			if _, err := doc.DataAt(r.opts.softDeleteField); err != nil {
+				missing = append(missing, doc)
+			}
+		}
+
+		// The precondition keeps a concurrent Delete from being overwritten; rerunning picks up
+		// documents that changed in between.
+		results := make([]BulkResult, len(missing))
+		r.bulkWrite(ctx, results, allIndexes(len(missing)), func(bw *firestore.BulkWriter, i int) (*firestore.BulkWriterJob, error) {
+			results[i].ID = missing[i].Ref.ID
+			return bw.Update(missing[i].Ref, r.notDeletedUpdate(), firestore.LastUpdateTime(missing[i].UpdateTime))
+		})
+		for _, res := range results {
+			if res.Err != nil {
+				return updated, res.Err
+			}
+			updated++
+		}
+
+		if len(docs) < backfillPageSize {
+			return updated, nil
+		}
+		page = q.StartAfter(docs[len(docs)-1])
+	}
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+func TestBackfillSoftDelete(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryRepository[*testUser, testUser]("User", WithSoftDelete())
+	if _, err := BackfillSoftDelete[*testUser, testUser](ctx, memory); !stderrors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported for the in-memory repository, got %v", err)
+	}
+
+	repo := emulatorRepository[*testUser, testUser](t, "User", WithSoftDelete())
+	// A document written before soft delete was enabled has no deletion field.
+	legacy, _, err := repo.collection().Add(ctx, map[string]interface{}{"email": "legacy@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(ctx, &testUser{Email: "new@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err := repo.Count(ctx, &query.QueryOptions{}); err != nil || count != 1 {
+		t.Fatalf("Expected the legacy document to be hidden before the backfill, got %d, %v", count, err)
+	}
+	if _, err := repo.GetByID(ctx, legacy.ID); err != nil {
+		t.Fatalf("Expected GetByID to find the legacy document, got %v", err)
+	}
+
+	updated, err := BackfillSoftDelete[*testUser, testUser](ctx, repo)
+	if err != nil || updated != 1 {
+		t.Fatalf("Expected one backfilled document, got %d, %v", updated, err)
+	}
+	if count, err := repo.Count(ctx, &query.QueryOptions{}); err != nil || count != 2 {
+		t.Errorf("Expected both documents after the backfill, got %d, %v", count, err)
+	}
+	if updated, err := BackfillSoftDelete[*testUser, testUser](ctx, repo); err != nil || updated != 0 {
+		t.Errorf("Expected a second backfill to update nothing, got %d, %v", updated, err)
+	}
+}
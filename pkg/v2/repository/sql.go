@@ -0,0 +1,1439 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"iter"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+// SqlDialect describes the placeholder and identifier quoting style of a database.
+type SqlDialect int
+
+const (
+	SqliteDialect SqlDialect = iota
+	PostgresDialect
+	MysqlDialect
+)
+
+func (d SqlDialect) placeholder(n int) string {
+	if d == PostgresDialect {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+func (d SqlDialect) quote(identifier string) string {
+	if d == MysqlDialect {
+		return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// nullsFirst reports whether the dialect sorts NULL before other values in ascending order.
+func (d SqlDialect) nullsFirst() bool {
+	return d != PostgresDialect
+}
+
+const sqlIDColumn = "id"
+
+type sqlColumn struct {
+	Name  string
+	Field fieldInfo
+	JSON  bool
+}
+
+type sqlTable struct {
+	Name    string
+	Dialect SqlDialect
+	Columns []sqlColumn
+	// Ressource names the entity in errors about query parameters.
+	Ressource string
+	// SoftDelete is the deletion timestamp column, empty if soft delete is disabled.
+	SoftDelete string
+	// selected are the indexes of the columns queries read, nil for all columns.
+	selected []int
+}
+
+// newSqlTable maps the persisted fields of t to columns. The column name is taken from the
+// `db` tag and falls back to the Firestore name, so the same entity works with both stores.
+func newSqlTable(name string, dialect SqlDialect, t reflect.Type) *sqlTable {
+	table := &sqlTable{Name: name, Dialect: dialect}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for _, f := range structFields(t) {
+		column := f.Name
+		if tag, _, _ := strings.Cut(t.FieldByIndex(f.Index).Tag.Get("db"), ","); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			column = tag
+		}
+		if column == sqlIDColumn {
+			continue
+		}
+		table.Columns = append(table.Columns, sqlColumn{
+			Name:  column,
+			Field: f,
+			JSON:  isJSONType(f.Type),
+		})
+	}
+	return table
+}
+
+func isJSONType(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(scannerType) || t.Implements(valuerType) {
+		return false
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Interface:
+		return true
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// column resolves a filter or update path to a column by column or Firestore name.
+func (t *sqlTable) column(path string) (*sqlColumn, bool) {
+	for i, c := range t.Columns {
+		if c.Name == path || c.Field.Name == path {
+			return &t.Columns[i], true
+		}
+	}
+	return nil, false
+}
+
+// fieldName returns the Firestore name of the column path resolves to, or path itself. Encrypted
+// fields are looked up by Firestore name, so paths are mapped before values are encrypted.
+func (t *sqlTable) fieldName(path string) string {
+	if c, ok := t.column(path); ok {
+		return c.Field.Name
+	}
+	return path
+}
+
+// fieldData returns data keyed by Firestore names, or data itself if it already is.
+func (t *sqlTable) fieldData(data map[string]interface{}) map[string]interface{} {
+	var mapped map[string]interface{}
+	for path := range data {
+		if t.fieldName(path) != path {
+			mapped = make(map[string]interface{}, len(data))
+			break
+		}
+	}
+	if mapped == nil {
+		return data
+	}
+	for path, value := range data {
+		mapped[t.fieldName(path)] = value
+	}
+	return mapped
+}
+
+// fieldFilters returns opts with its filters addressed by Firestore names, or opts itself if they are.
+func (t *sqlTable) fieldFilters(opts *query.QueryOptions) *query.QueryOptions {
+	var filters []query.Filter
+	for i, f := range opts.Filters {
+		if name := t.fieldName(f.Field); name != f.Field {
+			if filters == nil {
+				filters = slices.Clone(opts.Filters)
+			}
+			filters[i].Field = name
+		}
+	}
+	if filters == nil {
+		return opts
+	}
+	mapped := *opts
+	mapped.Filters = filters
+	return &mapped
+}
+
+// project returns a copy of t whose queries read only the columns holding paths. A nested path
+// reads its whole column. Filters and the order can still use every column.
+func (t *sqlTable) project(paths []string) *sqlTable {
+	if paths == nil {
+		return t
+	}
+	projected := *t
+	projected.selected = []int{}
+	for i, c := range t.Columns {
+		for _, path := range paths {
+			if field, _, _ := strings.Cut(path, "."); field == c.Field.Name {
+				projected.selected = append(projected.selected, i)
+				break
+			}
+		}
+	}
+	return &projected
+}
+
+// readColumns returns the columns queries read.
+func (t *sqlTable) readColumns() []sqlColumn {
+	if t.selected == nil {
+		return t.Columns
+	}
+	columns := make([]sqlColumn, len(t.selected))
+	for i, idx := range t.selected {
+		columns[i] = t.Columns[idx]
+	}
+	return columns
+}
+
+func (t *sqlTable) columnList() string {
+	names := []string{t.Dialect.quote(sqlIDColumn)}
+	for _, c := range t.readColumns() {
+		names = append(names, t.Dialect.quote(c.Name))
+	}
+	return strings.Join(names, ", ")
+}
+
+type sqlArgs struct {
+	dialect SqlDialect
+	values  []interface{}
+}
+
+func (a *sqlArgs) add(value interface{}) string {
+	a.values = append(a.values, value)
+	return a.dialect.placeholder(len(a.values))
+}
+
+func (t *sqlTable) orderColumn(opts *query.QueryOptions) (string, error) {
+	if opts.OrderBy == "" || opts.OrderBy == "id" {
+		return "", nil
+	}
+	c, ok := t.column(opts.OrderBy)
+	if !ok {
+		return "", t.badRequest("sort", opts.OrderBy, "unknown order field "+opts.OrderBy)
+	}
+	return c.Name, nil
+}
+
+// badRequest reports a query parameter that cannot be applied to the table.
+func (t *sqlTable) badRequest(field, value, message string) error {
+	return &errors.ErrorBadRequest{
+		ErrorDetail: errors.ErrorDetail{
+			Resource: t.Ressource,
+			Field:    field,
+			Value:    value,
+			Message:  message,
+		},
+	}
+}
+
+// whereClause translates the filters into a parameterized condition.
+func (t *sqlTable) whereClause(filters []query.Filter, args *sqlArgs) (string, error) {
+	var conditions []string
+	for _, f := range filters {
+		column := sqlIDColumn
+		if f.Field != "id" {
+			c, ok := t.column(f.Field)
+			if !ok {
+				return "", t.badRequest(f.Field, f.Field, "unknown filter field "+f.Field)
+			}
+			column = c.Name
+		}
+		column = t.Dialect.quote(column)
+
+		switch f.Operator {
+		case query.Contains:
+			values := reflect.ValueOf(f.Value)
+			if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+				return "", t.badRequest(f.Field, fmt.Sprint(f.Value), "filter "+f.Field+" requires a list value")
+			}
+			if values.Len() == 0 {
+				conditions = append(conditions, "1 = 0")
+				continue
+			}
+			placeholders := make([]string, values.Len())
+			for i := range placeholders {
+				placeholders[i] = args.add(values.Index(i).Interface())
+			}
+			conditions = append(conditions, fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", ")))
+		case query.ArrayContains:
+			return "", fmt.Errorf("filter operator %s: %w", f.Operator, ErrNotSupported)
+		case query.Gt, query.Gte, query.Lt, query.Lte:
+			op := map[query.Operator]string{query.Gt: ">", query.Gte: ">=", query.Lt: "<", query.Lte: "<="}[f.Operator]
+			conditions = append(conditions, fmt.Sprintf("%s %s %s", column, op, args.add(f.Value)))
+		default:
+			if f.Value == nil {
+				conditions = append(conditions, column+" IS NULL")
+				continue
+			}
+			conditions = append(conditions, fmt.Sprintf("%s = %s", column, args.add(f.Value)))
+		}
+	}
+	return strings.Join(conditions, " AND "), nil
+}
+
+// selectQuery builds a keyset paginated query. cursor holds the order value of the cursor row
+// (ignored when ordering by id) and backwards selects the page before the cursor in reverse order.
+func (t *sqlTable) selectQuery(opts *query.QueryOptions, cursorID string, cursor interface{}, backwards bool) (string, []interface{}, error) {
+	args := &sqlArgs{dialect: t.Dialect}
+	where, err := t.whereClause(opts.Filters, args)
+	if err != nil {
+		return "", nil, err
+	}
+	if t.SoftDelete != "" && !opts.IncludeDeleted {
+		where = t.joinConditions(where, t.notDeleted())
+	}
+	orderColumn, err := t.orderColumn(opts)
+	if err != nil {
+		return "", nil, err
+	}
+
+	desc := opts.OrderBy != "" && opts.OrderByDirection == query.Desc
+	if backwards {
+		desc = !desc
+	}
+	direction, cmp := "ASC", ">"
+	if desc {
+		direction, cmp = "DESC", "<"
+	}
+
+	id := t.Dialect.quote(sqlIDColumn)
+	if cursorID != "" {
+		var keyset string
+		if orderColumn == "" {
+			keyset = fmt.Sprintf("%s %s %s", id, cmp, args.add(cursorID))
+		} else {
+			keyset = t.keyset(orderColumn, cmp, desc, cursor, cursorID, args)
+		}
+		where = t.joinConditions(where, keyset)
+	}
+
+	stmt := fmt.Sprintf("SELECT %s FROM %s", t.columnList(), t.Dialect.quote(t.Name))
+	if where != "" {
+		stmt += " WHERE " + where
+	}
+	if orderColumn != "" {
+		stmt += fmt.Sprintf(" ORDER BY %s %s, %s %s", t.Dialect.quote(orderColumn), direction, id, direction)
+	} else {
+		stmt += fmt.Sprintf(" ORDER BY %s %s", id, direction)
+	}
+	if opts.Limit > 0 {
+		stmt += " LIMIT " + strconv.Itoa(opts.Limit)
+	}
+	return stmt, args.values, nil
+}
+
+// keyset returns the condition selecting the rows after the cursor row in the order of orderColumn.
+// Comparisons with NULL are never true, so NULL order values are matched with IS NULL and placed
+// where the dialect sorts them.
+func (t *sqlTable) keyset(orderColumn, cmp string, desc bool, cursor interface{}, cursorID string, args *sqlArgs) string {
+	col, id := t.Dialect.quote(orderColumn), t.Dialect.quote(sqlIDColumn)
+	nullsAfter := t.Dialect.nullsFirst() == desc
+	if cursor == nil {
+		keyset := fmt.Sprintf("%s IS NULL AND %s %s %s", col, id, cmp, args.add(cursorID))
+		if nullsAfter {
+			return "(" + keyset + ")"
+		}
+		return fmt.Sprintf("(%s OR %s IS NOT NULL)", keyset, col)
+	}
+	keyset := fmt.Sprintf("%s %s %s OR (%s = %s AND %s %s %s)",
+		col, cmp, args.add(cursor), col, args.add(cursor), id, cmp, args.add(cursorID))
+	if nullsAfter {
+		return fmt.Sprintf("(%s OR %s IS NULL)", keyset, col)
+	}
+	return "(" + keyset + ")"
+}
+
+// aggregateQuery counts the rows matching opts and sums and averages the columns of agg.
+func (t *sqlTable) aggregateQuery(opts *query.QueryOptions, agg Aggregation) (string, []interface{}, error) {
+	args := &sqlArgs{dialect: t.Dialect}
+	where, err := t.whereClause(opts.Filters, args)
+	if err != nil {
+		return "", nil, err
+	}
+	if t.SoftDelete != "" && !opts.IncludeDeleted {
+		where = t.joinConditions(where, t.notDeleted())
+	}
+
+	exprs := []string{"COUNT(*)"}
+	for _, fn := range []struct {
+		name   string
+		fields []string
+	}{{"SUM", agg.Sum}, {"AVG", agg.Avg}} {
+		for _, field := range fn.fields {
+			c, ok := t.column(field)
+			if !ok {
+				return "", nil, fmt.Errorf("unknown aggregation field %s", field)
+			}
+			exprs = append(exprs, fmt.Sprintf("%s(%s)", fn.name, t.Dialect.quote(c.Name)))
+		}
+	}
+
+	stmt := fmt.Sprintf("SELECT %s FROM %s", strings.Join(exprs, ", "), t.Dialect.quote(t.Name))
+	if where != "" {
+		stmt += " WHERE " + where
+	}
+	return stmt, args.values, nil
+}
+
+func (t *sqlTable) notDeleted() string {
+	return t.Dialect.quote(t.SoftDelete) + " IS NULL"
+}
+
+func (t *sqlTable) joinConditions(conditions ...string) string {
+	var parts []string
+	for _, c := range conditions {
+		if c != "" {
+			parts = append(parts, c)
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+func (t *sqlTable) insertQuery(id string, obj reflect.Value) (string, []interface{}, error) {
+	args := &sqlArgs{dialect: t.Dialect}
+	names := []string{t.Dialect.quote(sqlIDColumn)}
+	placeholders := []string{args.add(id)}
+	for _, c := range t.Columns {
+		fv, err := obj.FieldByIndexErr(c.Field.Index)
+		if err != nil {
+			fv = reflect.Zero(c.Field.Type)
+		}
+		value, err := columnValue(c, fv)
+		if err != nil {
+			return "", nil, err
+		}
+		names = append(names, t.Dialect.quote(c.Name))
+		placeholders = append(placeholders, args.add(value))
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		t.Dialect.quote(t.Name), strings.Join(names, ", "), strings.Join(placeholders, ", "))
+	return stmt, args.values, nil
+}
+
+// updateQuery builds the UPDATE for data. The Version column is incremented unless data sets it,
+// and a non-nil expectedVersion restricts the update to rows still having that version.
+func (t *sqlTable) updateQuery(id string, data map[string]interface{}, expectedVersion *int64) (string, []interface{}, error) {
+	if len(data) == 0 {
+		return "", nil, fmt.Errorf("no fields to update")
+	}
+	paths := make([]string, 0, len(data))
+	for path := range data {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	args := &sqlArgs{dialect: t.Dialect}
+	assignments := make([]string, 0, len(paths))
+	for _, path := range paths {
+		c, ok := t.column(path)
+		if !ok {
+			return "", nil, fmt.Errorf("unknown field %s", path)
+		}
+		value := data[path]
+		if value == firestore.ServerTimestamp {
+			value = time.Now()
+		}
+		if value == firestore.Delete {
+			value = nil
+		}
+		if value != nil {
+			var err error
+			if value, err = columnValue(*c, reflect.ValueOf(value)); err != nil {
+				return "", nil, err
+			}
+		}
+		assignments = append(assignments, fmt.Sprintf("%s = %s", t.Dialect.quote(c.Name), args.add(value)))
+	}
+	version := t.versionColumn()
+	if version != nil {
+		if _, set := data[version.Field.Name]; !set {
+			col := t.Dialect.quote(version.Name)
+			assignments = append(assignments, fmt.Sprintf("%s = %s + 1", col, col))
+		}
+	}
+	stmt := fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s",
+		t.Dialect.quote(t.Name), strings.Join(assignments, ", "), t.Dialect.quote(sqlIDColumn), args.add(id))
+	if expectedVersion != nil {
+		if version == nil {
+			return "", nil, fmt.Errorf("versioned updates require an integer Version field: %w", ErrNotSupported)
+		}
+		stmt += fmt.Sprintf(" AND %s = %s", t.Dialect.quote(version.Name), args.add(*expectedVersion))
+	}
+	return stmt, args.values, nil
+}
+
+// versionColumn returns the column of the integer Version field, if the entity has one.
+func (t *sqlTable) versionColumn() *sqlColumn {
+	for i, c := range t.Columns {
+		if c.Field.GoName == versionFieldName && len(c.Field.Index) == 1 && isIntegerKind(c.Field.Type.Kind()) {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+// columnValue converts a field value to a driver value, encoding composite types as JSON.
+func columnValue(c sqlColumn, v reflect.Value) (interface{}, error) {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, nil
+	}
+	if !c.JSON {
+		return v.Interface(), nil
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// fieldScanner scans a column into a struct field, tolerating NULL and driver specific types.
+type fieldScanner struct {
+	dst  reflect.Value
+	json bool
+}
+
+func (s *fieldScanner) Scan(src interface{}) error {
+	if src == nil {
+		s.dst.Set(reflect.Zero(s.dst.Type()))
+		return nil
+	}
+	if s.dst.Addr().Type().Implements(scannerType) {
+		return s.dst.Addr().Interface().(sql.Scanner).Scan(src)
+	}
+	if b, ok := src.([]byte); ok {
+		src = string(b)
+	}
+	if s.json {
+		text, ok := src.(string)
+		if !ok {
+			return fmt.Errorf("cannot decode %T as JSON", src)
+		}
+		return json.Unmarshal([]byte(text), s.dst.Addr().Interface())
+	}
+
+	dst := s.dst
+	if dst.Kind() == reflect.Pointer {
+		dst.Set(reflect.New(dst.Type().Elem()))
+		dst = dst.Elem()
+	}
+	switch {
+	case dst.Type() == timeType:
+		if text, ok := src.(string); ok {
+			parsed, err := time.Parse(time.RFC3339Nano, text)
+			if err != nil {
+				return err
+			}
+			src = parsed
+		}
+	case dst.Kind() == reflect.Bool:
+		if n, ok := src.(int64); ok {
+			src = n != 0
+		}
+	case dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8:
+		if text, ok := src.(string); ok {
+			src = []byte(text)
+		}
+	}
+	return assignValue(dst, src)
+}
+
+func (t *sqlTable) scan(rows interface{ Scan(...interface{}) error }, obj reflect.Value) (string, error) {
+	var id string
+	dest := []interface{}{&id}
+	for _, c := range t.readColumns() {
+		fv := obj
+		for _, idx := range c.Field.Index {
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			fv = fv.Field(idx)
+		}
+		dest = append(dest, &fieldScanner{dst: fv, json: c.JSON})
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+type sqlRepository[T Entity, TT any] struct {
+	Db        *sql.DB
+	Table     *sqlTable
+	Ressource string
+	opts      *options
+}
+
+// NewSqlRepository creates a Repository backed by database/sql. The table is named like the
+// Firestore collection, honouring WithCollectionName, WithPluralizer, WithCollectionPrefix and
+// WithCollectionSuffix, and needs an "id" text primary key plus one column per persisted field. With soft delete enabled the table also needs the nullable deletion column.
+func NewSqlRepository[T Entity, TT any](db *sql.DB, ressource string, dialect SqlDialect, opts ...Option) Repository[T, TT] {
+	o := newOptions(opts)
+
+	table := newSqlTable(o.collection(ressource), dialect, reflect.TypeOf((*T)(nil)).Elem())
+	table.Ressource = ressource
+	if o.softDelete {
+		table.SoftDelete = o.softDeleteField
+	}
+
+	return &sqlRepository[T, TT]{
+		Db:        db,
+		Table:     table,
+		Ressource: ressource,
+		opts:      o,
+	}
+}
+
+func (r *sqlRepository[T, TT]) configuration() *options {
+	return r.opts
+}
+
+func (r *sqlRepository[T, TT]) GetClient() *firestore.Client {
+	return nil
+}
+
+func (r *sqlRepository[T, TT]) scanEntity(ctx context.Context, row interface{ Scan(...interface{}) error }) (T, error) {
+	return r.scanColumns(ctx, r.Table, row)
+}
+
+// scanColumns scans a row holding the columns table reads into a new entity.
+func (r *sqlRepository[T, TT]) scanColumns(ctx context.Context, table *sqlTable, row interface{ Scan(...interface{}) error }) (T, error) {
+	obj := newEntity[T]()
+	val, ok := indirect(reflect.ValueOf(obj))
+	if !ok || val.Kind() != reflect.Struct || !val.CanAddr() {
+		return obj, fmt.Errorf("entity must be a struct pointer")
+	}
+	id, err := table.scan(row, val)
+	if err != nil {
+		return obj, err
+	}
+	if err := openEntity(r.opts, obj); err != nil {
+		return obj, err
+	}
+	obj.SetDocId(id)
+	if version, ok := entityVersion(obj); ok {
+		setDocVersion(obj, strconv.FormatInt(version, 10))
+	}
+	return obj, afterLoad(ctx, obj)
+}
+
+func (r *sqlRepository[T, TT]) Get(ctx context.Context, opts *query.QueryOptions) (*PaginationResult[T], error) {
+	if opts == nil {
+		opts = &query.QueryOptions{
+			Limit: 100,
+		}
+	}
+
+	cursor, backwards, err := r.opts.pageCursor(r.Ressource, opts)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := projection[T](r.Ressource, opts)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealQuery[T](r.opts, r.Ressource, r.Table.fieldFilters(opts))
+	if err != nil {
+		return nil, err
+	}
+	table := r.Table.project(paths)
+	var cursorID string
+	var cursorValue interface{}
+	if cursor != nil {
+		cursorID, cursorValue = cursor.ID, cursor.value
+	}
+
+	// One row more than requested tells whether another page follows.
+	page := *sealed
+	if page.Limit > 0 {
+		page.Limit++
+	}
+	stmt, args, err := table.selectQuery(&page, cursorID, cursorValue, backwards)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.Db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	objs := make([]T, 0)
+	for rows.Next() {
+		obj, err := r.scanColumns(ctx, table, rows)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	more := opts.Limit > 0 && len(objs) > opts.Limit
+	if more {
+		objs = objs[:opts.Limit]
+	}
+	if backwards {
+		for i, j := 0, len(objs)-1; i < j; i, j = i+1, j-1 {
+			objs[i], objs[j] = objs[j], objs[i]
+		}
+	}
+	hasNext, hasPrev := more, cursor != nil
+	if backwards {
+		hasNext, hasPrev = true, more
+	}
+
+	var first, last *pageCursor
+	if len(objs) > 0 {
+		if first, err = r.entityCursor(opts, objs[0]); err != nil {
+			return nil, err
+		}
+		if last, err = r.entityCursor(opts, objs[len(objs)-1]); err != nil {
+			return nil, err
+		}
+	}
+	nextPageKey, prevPageKey := r.opts.pageTokens(first, last, hasNext, hasPrev)
+
+	count, err := total(ctx, opts, r.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginationResult[T]{
+		Items:   objs,
+		Limit:   opts.Limit,
+		Next:    nextPageKey,
+		Prev:    prevPageKey,
+		Filters: &opts.Filters,
+		Total:   count,
+		HasNext: nextPageKey != "",
+		HasPrev: prevPageKey != "",
+	}, nil
+}
+
+// entityCursor returns the cursor of obj holding the column value it is ordered by.
+func (r *sqlRepository[T, TT]) entityCursor(opts *query.QueryOptions, obj T) (*pageCursor, error) {
+	var value reflect.Value
+	if orderBy, _ := pageOrder(opts); orderBy != "id" {
+		c, ok := r.Table.column(orderBy)
+		if !ok {
+			return nil, fmt.Errorf("unknown order field %s", orderBy)
+		}
+		fv, ok := lookupPath(reflect.ValueOf(obj), c.Field.Name)
+		if ok {
+			v, err := columnValue(*c, fv)
+			if err != nil {
+				return nil, err
+			}
+			value = reflect.ValueOf(v)
+		}
+	}
+	return newPageCursor(opts, obj.DocId(), value)
+}
+
+func (r *sqlRepository[T, TT]) Count(ctx context.Context, opts *query.QueryOptions) (int64, error) {
+	res, err := r.Aggregate(ctx, opts, Aggregation{})
+	if err != nil {
+		return 0, err
+	}
+	return res.Count, nil
+}
+
+// Aggregate computes the count, sums and averages with a single SELECT. The fields of agg must be
+// numeric columns.
+func (r *sqlRepository[T, TT]) Aggregate(ctx context.Context, opts *query.QueryOptions, agg Aggregation) (*AggregationResult, error) {
+	if opts == nil {
+		opts = &query.QueryOptions{}
+	}
+	opts, err := sealQuery[T](r.opts, r.Ressource, r.Table.fieldFilters(opts))
+	if err != nil {
+		return nil, err
+	}
+	stmt, args, err := r.Table.aggregateQuery(opts, agg)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	values := make([]sql.NullFloat64, len(agg.Sum)+len(agg.Avg))
+	dest := []interface{}{&count}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	if err := r.Db.QueryRowContext(ctx, stmt, args...).Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	res := newAggregationResult(count)
+	for i, field := range agg.Sum {
+		res.Sum[field] = values[i].Float64
+	}
+	for i, field := range agg.Avg {
+		if avg := values[len(agg.Sum)+i]; avg.Valid {
+			res.Avg[field] = avg.Float64
+		}
+	}
+	return res, nil
+}
+
+// All iterates over every document matching opts, fetching pages of opts.Limit lazily.
+// Iteration stops at the first error, which is yielded with a zero value.
+func (r *sqlRepository[T, TT]) All(ctx context.Context, opts *query.QueryOptions) iter.Seq2[T, error] {
+	return allPages(ctx, r.Get, opts)
+}
+
+func (r *sqlRepository[T, TT]) GetByID(ctx context.Context, id string) (*T, error) {
+	if id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+
+	obj, err := r.getByID(ctx, r.Db, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &obj, nil
+}
+
+// GetByIDs loads the rows of ids with a single query.
+func (r *sqlRepository[T, TT]) GetByIDs(ctx context.Context, ids []string) ([]T, []string, error) {
+	ids, err := uniqueIDs(ids)
+	if err != nil || len(ids) == 0 {
+		return nil, nil, err
+	}
+
+	opts := &query.QueryOptions{Filters: []query.Filter{{Field: "id", Operator: query.Contains, Value: ids}}}
+	stmt, args, err := r.Table.selectQuery(opts, "", nil, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := r.Db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string]T, len(ids))
+	for rows.Next() {
+		obj, err := r.scanEntity(ctx, rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		found[obj.DocId()] = obj
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	objs, missing := inOrder(ids, found)
+	return objs, missing, nil
+}
+
+func (r *sqlRepository[T, TT]) History(ctx context.Context, id string, opts *query.QueryOptions) (*PaginationResult[*AuditRecord], error) {
+	return nil, fmt.Errorf("History: %w", ErrNotSupported)
+}
+
+func (r *sqlRepository[T, TT]) Watch(ctx context.Context, opts *query.QueryOptions) iter.Seq2[WatchEvent[T], error] {
+	return func(yield func(WatchEvent[T], error) bool) {
+		yield(WatchEvent[T]{}, fmt.Errorf("Watch: %w", ErrNotSupported))
+	}
+}
+
+func (r *sqlRepository[T, TT]) CreateQueryNotExists(ctx context.Context, obj T, funcQuery func(firestore.Query) firestore.Query) (*string, error) {
+	return nil, fmt.Errorf("CreateQueryNotExists: %w", ErrNotSupported)
+}
+
+func (r *sqlRepository[T, TT]) Create(ctx context.Context, obj T) (*string, error) {
+	if err := r.unaudited(); err != nil {
+		return nil, err
+	}
+	var docID string
+	err := r.runInTx(ctx, func(tx *sql.Tx) error {
+		if err := beforeCreate(ctx, obj); err != nil {
+			return err
+		}
+		if err := r.opts.validate(obj); err != nil {
+			return err
+		}
+		if err := r.checkUnique(ctx, tx, "", obj.UniqFields()); err != nil {
+			return err
+		}
+		id, err := r.insert(ctx, tx, obj)
+		docID = id
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &docID, nil
+}
+
+func (r *sqlRepository[T, TT]) CreateEasy(ctx context.Context, obj T) (*string, error) {
+	if err := r.unaudited(); err != nil {
+		return nil, err
+	}
+	if err := beforeCreate(ctx, obj); err != nil {
+		return nil, err
+	}
+	if err := r.opts.validate(obj); err != nil {
+		return nil, err
+	}
+	if !implements[AfterCreator, T]() {
+		docID, err := r.insert(ctx, r.Db, obj)
+		if err != nil {
+			return nil, err
+		}
+		return &docID, nil
+	}
+
+	var docID string
+	err := r.runInTx(ctx, func(tx *sql.Tx) error {
+		id, err := r.insert(ctx, tx, obj)
+		docID = id
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &docID, nil
+}
+
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (r *sqlRepository[T, TT]) insert(ctx context.Context, db sqlExecer, obj T) (string, error) {
+	prepareCreate(obj)
+	stored, err := sealEntity(r.opts, obj)
+	if err != nil {
+		return "", err
+	}
+	val, ok := indirect(reflect.ValueOf(stored))
+	if !ok || val.Kind() != reflect.Struct {
+		return "", fmt.Errorf("entity must be a struct pointer")
+	}
+
+	docID := newDocID()
+	stmt, args, err := r.Table.insertQuery(docID, val)
+	if err != nil {
+		return "", err
+	}
+	if _, err := db.ExecContext(ctx, stmt, args...); err != nil {
+		if isUniqueViolation(err) {
+			return "", alreadyExists(r.Ressource, obj.UniqFields())
+		}
+		return "", err
+	}
+	obj.SetDocId(docID)
+	if err := afterCreate(ctx, obj); err != nil {
+		return "", err
+	}
+	return docID, nil
+}
+
+// Upsert creates obj or updates the row holding all of its UniqFields in one transaction.
+// Soft deleted rows are not matched.
+func (r *sqlRepository[T, TT]) Upsert(ctx context.Context, obj T) (*string, bool, error) {
+	if err := r.unaudited(); err != nil {
+		return nil, false, err
+	}
+	uniq := obj.UniqFields()
+	if len(uniq) == 0 {
+		return nil, false, errUniqFieldsRequired
+	}
+
+	var docID string
+	var created bool
+	err := r.runInTx(ctx, func(tx *sql.Tx) error {
+		matches, err := r.matching(ctx, tx, uniq)
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			if err := beforeCreate(ctx, obj); err != nil {
+				return err
+			}
+			if err := r.opts.validate(obj); err != nil {
+				return err
+			}
+			if err := r.checkUnique(ctx, tx, "", uniq); err != nil {
+				return err
+			}
+			created = true
+			docID, err = r.insert(ctx, tx, obj)
+			return err
+		}
+		if len(matches) > 1 {
+			return alreadyExists(r.Ressource, uniq)
+		}
+
+		prepareUpsert(obj, matches[0])
+		if err := r.opts.validate(obj); err != nil {
+			return err
+		}
+		docID = matches[0].DocId()
+		if _, err := r.updateInTx(ctx, tx, docID, r.opts.upsertData(obj), nil); err != nil {
+			return err
+		}
+		obj.SetDocId(docID)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &docID, created, nil
+}
+
+// matching returns up to two rows holding all values of fields, enough to tell whether one matches.
+func (r *sqlRepository[T, TT]) matching(ctx context.Context, tx *sql.Tx, fields map[string]interface{}) ([]T, error) {
+	filters, err := uniqueFilters[T](r.opts, fields)
+	if err != nil {
+		return nil, err
+	}
+	opts := &query.QueryOptions{Filters: filters, Limit: 2}
+	stmt, args, err := r.Table.selectQuery(opts, "", nil, false)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objs []T
+	for rows.Next() {
+		obj, err := r.scanEntity(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+	return objs, rows.Err()
+}
+
+// checkUnique fails if a row other than id holds the given unique values.
+func (r *sqlRepository[T, TT]) checkUnique(ctx context.Context, tx *sql.Tx, id string, uniq map[string]interface{}) error {
+	for _, group := range r.opts.uniqueGroups(uniq) {
+		if err := r.checkUniqueGroup(ctx, tx, id, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkUniqueGroup fails if a row other than id holds all the given values.
+func (r *sqlRepository[T, TT]) checkUniqueGroup(ctx context.Context, tx *sql.Tx, id string, fields map[string]interface{}) error {
+	filters, err := uniqueFilters[T](r.opts, fields)
+	if err != nil {
+		return err
+	}
+	args := &sqlArgs{dialect: r.Table.Dialect}
+	where, err := r.Table.whereClause(filters, args)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		where = r.Table.joinConditions(where, fmt.Sprintf("%s <> %s", r.Table.Dialect.quote(sqlIDColumn), args.add(id)))
+	}
+	if r.opts.excludesDeletedFromUnique() {
+		where = r.Table.joinConditions(where, r.Table.notDeleted())
+	}
+	stmt := fmt.Sprintf("SELECT 1 FROM %s", r.Table.Dialect.quote(r.Table.Name))
+	if where != "" {
+		stmt += " WHERE " + where
+	}
+	stmt += " LIMIT 1"
+
+	var exists int
+	err = tx.QueryRowContext(ctx, stmt, args.values...).Scan(&exists)
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return alreadyExists(r.Ressource, fields)
+}
+
+// unaudited fails every write with ErrNotSupported if WithAudit is set, as the SQL repository
+// cannot record an audit trail and must not write without one.
+func (r *sqlRepository[T, TT]) unaudited() error {
+	if r.opts.audit {
+		return fmt.Errorf("audit: %w", ErrNotSupported)
+	}
+	return nil
+}
+
+func (r *sqlRepository[T, TT]) runInTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqlRepository[T, TT]) Update(ctx context.Context, id string, data map[string]interface{}) error {
+	if err := r.unaudited(); err != nil {
+		return err
+	}
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	res, err := r.update(ctx, id, data, nil)
+	if err != nil {
+		return err
+	}
+	return r.affected(ctx, r.Db, id, res)
+}
+
+// affected fails with ErrorNotFound if res changed no row and the row id does not exist. MySQL
+// counts only rows whose values changed, so an update writing the current values affects none.
+func (r *sqlRepository[T, TT]) affected(ctx context.Context, db sqlQueryer, id string, res sql.Result) error {
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return nil
+	}
+	stmt := fmt.Sprintf("SELECT 1 FROM %s WHERE %s = %s",
+		r.Table.Dialect.quote(r.Table.Name), r.Table.Dialect.quote(sqlIDColumn), r.Table.Dialect.placeholder(1))
+	var exists int
+	err := db.QueryRowContext(ctx, stmt, id).Scan(&exists)
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return r.notFound(id)
+	}
+	return err
+}
+
+// update runs the update statement for data. If the entity has a BeforeUpdate hook or data
+// touches a unique field, the row is read first and the new unique values are checked against
+// all other rows in the same transaction.
+func (r *sqlRepository[T, TT]) update(ctx context.Context, id string, data map[string]interface{}, expectedVersion *int64) (sql.Result, error) {
+	if !implements[BeforeUpdater, T]() && !touchesUnique(newEntity[T]().UniqFields(), data) {
+		return r.exec(ctx, r.Db, id, data, expectedVersion)
+	}
+
+	var res sql.Result
+	err := r.runInTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		res, err = r.updateInTx(ctx, tx, id, data, expectedVersion)
+		return err
+	})
+	return res, err
+}
+
+// updateInTx reads the row, runs BeforeUpdate and checks the unique values before it updates the row.
+func (r *sqlRepository[T, TT]) updateInTx(ctx context.Context, tx *sql.Tx, id string, data map[string]interface{}, expectedVersion *int64) (sql.Result, error) {
+	obj, err := r.getByID(ctx, tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	changes := data
+	if implements[BeforeUpdater, T]() {
+		changes = maps.Clone(data)
+		if err := beforeUpdate(ctx, obj, changes); err != nil {
+			return nil, err
+		}
+	}
+	if touchesUnique(obj.UniqFields(), changes) {
+		uniq, err := updatedUniqFields(obj, changes)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.checkUnique(ctx, tx, id, uniq); err != nil {
+			return nil, err
+		}
+	}
+	return r.exec(ctx, tx, id, changes, expectedVersion)
+}
+
+// exec runs the update statement for data, encrypting the values of encrypted fields.
+func (r *sqlRepository[T, TT]) exec(ctx context.Context, db sqlExecer, id string, data map[string]interface{}, expectedVersion *int64) (sql.Result, error) {
+	data, err := sealData[T](r.opts, r.Table.fieldData(data))
+	if err != nil {
+		return nil, err
+	}
+	stmt, args, err := r.Table.updateQuery(id, data, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	res, err := db.ExecContext(ctx, stmt, args...)
+	if err != nil && isUniqueViolation(err) {
+		return nil, alreadyExists(r.Ressource, nil)
+	}
+	return res, err
+}
+
+// Patch updates the masked fields of patch. Nested paths are rejected, as JSON columns are written as a whole.
+func (r *sqlRepository[T, TT]) Patch(ctx context.Context, id string, patch interface{}, mask ...string) error {
+	data, err := patchData(r.Ressource, reflect.TypeOf((*T)(nil)).Elem(), patch, mask)
+	if err != nil {
+		return err
+	}
+	if err := r.opts.validatePatch(patch, mask); err != nil {
+		return err
+	}
+	return r.Update(ctx, id, data)
+}
+
+// UpdateWithVersion applies data only if the row still has the given integer version.
+func (r *sqlRepository[T, TT]) UpdateWithVersion(ctx context.Context, id string, version string, data map[string]interface{}) error {
+	if err := r.unaudited(); err != nil {
+		return err
+	}
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	expected, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return preconditionFailed(r.Ressource, id, version)
+	}
+	res, err := r.update(ctx, id, data, &expected)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if _, err := r.getByID(ctx, r.Db, id, true); err != nil {
+			return err
+		}
+		return preconditionFailed(r.Ressource, id, version)
+	}
+	return nil
+}
+
+func (r *sqlRepository[T, TT]) Delete(ctx context.Context, id string) error {
+	if err := r.unaudited(); err != nil {
+		return err
+	}
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	if !r.opts.softDelete {
+		return r.Purge(ctx, id)
+	}
+	if !implements[BeforeDeleter, T]() {
+		return r.setDeleted(ctx, r.Db, id, time.Now())
+	}
+	return r.runInTx(ctx, func(tx *sql.Tx) error {
+		if err := r.beforeDelete(ctx, tx, id); err != nil {
+			return err
+		}
+		return r.setDeleted(ctx, tx, id, time.Now())
+	})
+}
+
+func (r *sqlRepository[T, TT]) Restore(ctx context.Context, id string) error {
+	if err := r.unaudited(); err != nil {
+		return err
+	}
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+	if !r.opts.softDelete {
+		return fmt.Errorf("soft delete is not enabled for %s", r.Ressource)
+	}
+
+	return r.runInTx(ctx, func(tx *sql.Tx) error {
+		if r.opts.excludesDeletedFromUnique() {
+			obj, err := r.getByID(ctx, tx, id, true)
+			if err != nil {
+				return err
+			}
+			if err := r.checkUnique(ctx, tx, id, obj.UniqFields()); err != nil {
+				return err
+			}
+		}
+		return r.setDeleted(ctx, tx, id, nil)
+	})
+}
+
+func (r *sqlRepository[T, TT]) Purge(ctx context.Context, id string) error {
+	if err := r.unaudited(); err != nil {
+		return err
+	}
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	if !implements[BeforeDeleter, T]() {
+		return r.purge(ctx, r.Db, id)
+	}
+	return r.runInTx(ctx, func(tx *sql.Tx) error {
+		if err := r.beforeDelete(ctx, tx, id); err != nil {
+			return err
+		}
+		return r.purge(ctx, tx, id)
+	})
+}
+
+func (r *sqlRepository[T, TT]) purge(ctx context.Context, db sqlExecer, id string) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE %s = %s",
+		r.Table.Dialect.quote(r.Table.Name), r.Table.Dialect.quote(sqlIDColumn), r.Table.Dialect.placeholder(1))
+	res, err := db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return r.notFound(id)
+	}
+	return nil
+}
+
+// beforeDelete runs the BeforeDelete hook on the stored row. A missing row is left to the delete itself.
+func (r *sqlRepository[T, TT]) beforeDelete(ctx context.Context, tx *sql.Tx, id string) error {
+	obj, err := r.getByID(ctx, tx, id, true)
+	var notFound *errors.ErrorNotFound
+	if stderrors.As(err, &notFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return beforeDelete(ctx, obj)
+}
+
+// CreateMany inserts objs concurrently. Like CreateEasy it does not check UniqFields, but unique
+// constraints of the table still apply per row.
+func (r *sqlRepository[T, TT]) CreateMany(ctx context.Context, objs []T) ([]BulkResult, error) {
+	if err := r.unaudited(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return bulkEach(len(objs), r.opts.bulkConcurrency, func(i int) (string, error) {
+		docID, err := r.CreateEasy(ctx, objs[i])
+		if err != nil {
+			return "", err
+		}
+		return *docID, nil
+	}), nil
+}
+
+// UpdateMany applies every update and stamps UpdatedAt like Create does.
+func (r *sqlRepository[T, TT]) UpdateMany(ctx context.Context, updates []BulkUpdate) ([]BulkResult, error) {
+	if err := r.unaudited(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	return bulkEach(len(updates), r.opts.bulkConcurrency, func(i int) (string, error) {
+		return updates[i].ID, r.Update(ctx, updates[i].ID, stampUpdatedAt(t, updates[i].Data))
+	}), nil
+}
+
+// DeleteMany deletes, or soft deletes, every id.
+func (r *sqlRepository[T, TT]) DeleteMany(ctx context.Context, ids []string) ([]BulkResult, error) {
+	if err := r.unaudited(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return bulkEach(len(ids), r.opts.bulkConcurrency, func(i int) (string, error) {
+		return ids[i], r.Delete(ctx, ids[i])
+	}), nil
+}
+
+func (r *sqlRepository[T, TT]) setDeleted(ctx context.Context, db sqlDB, id string, deletedAt interface{}) error {
+	stmt := fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s = %s",
+		r.Table.Dialect.quote(r.Table.Name), r.Table.Dialect.quote(r.Table.SoftDelete), r.Table.Dialect.placeholder(1),
+		r.Table.Dialect.quote(sqlIDColumn), r.Table.Dialect.placeholder(2))
+	res, err := db.ExecContext(ctx, stmt, deletedAt, id)
+	if err != nil {
+		return err
+	}
+	return r.affected(ctx, db, id, res)
+}
+
+type sqlQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// sqlDB is a database or a transaction.
+type sqlDB interface {
+	sqlExecer
+	sqlQueryer
+}
+
+func (r *sqlRepository[T, TT]) getByID(ctx context.Context, db sqlQueryer, id string, includeDeleted bool) (T, error) {
+	where := fmt.Sprintf("%s = %s", r.Table.Dialect.quote(sqlIDColumn), r.Table.Dialect.placeholder(1))
+	if r.opts.softDelete && !includeDeleted {
+		where = r.Table.joinConditions(where, r.Table.notDeleted())
+	}
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE %s", r.Table.columnList(), r.Table.Dialect.quote(r.Table.Name), where)
+	obj, err := r.scanEntity(ctx, db.QueryRowContext(ctx, stmt, id))
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return obj, r.notFound(id)
+	}
+	return obj, err
+}
+
+func (r *sqlRepository[T, TT]) notFound(id string) error {
+	return &errors.ErrorNotFound{
+		ErrorDetail: errors.ErrorDetail{
+			Resource: r.Ressource,
+			Field:    "id",
+			Value:    id,
+			Message:  r.Ressource + " with id " + id + " not found",
+		},
+	}
+}
+
+// isUniqueViolation detects unique constraint errors of the common drivers without importing them.
+func isUniqueViolation(err error) bool {
+	var state interface{ SQLState() string }
+	if stderrors.As(err, &state) && state.SQLState() == "23505" {
+		return true
+	}
+	msg := err.Error()
+	for _, marker := range []string{
+		"UNIQUE constraint failed",
+		"duplicate key value violates unique constraint",
+		"Duplicate entry",
+		"SQLSTATE 23505",
+	} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
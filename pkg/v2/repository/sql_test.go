@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+type testSqlProfile struct {
+	City string `json:"city"`
+}
+
+type testSqlUser struct {
+	ID        string         `firestore:"-"`
+	Email     string         `firestore:"email"`
+	Name      string         `firestore:"name" db:"full_name"`
+	Age       int            `firestore:"age"`
+	Profile   testSqlProfile `firestore:"profile"`
+	Secret    string         `firestore:"secret" db:"-"`
+	CreatedAt time.Time      `firestore:"created_at"`
+}
+
+func TestSqlTableColumns(t *testing.T) {
+	table := newSqlTable("users", PostgresDialect, reflect.TypeOf(&testSqlUser{}))
+
+	want := `"id", "email", "full_name", "age", "profile", "created_at"`
+	if got := table.columnList(); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	c, ok := table.column("name")
+	if !ok || c.Name != "full_name" {
+		t.Errorf("Expected firestore name to resolve to full_name, got %+v", c)
+	}
+	if c, _ := table.column("profile"); !c.JSON {
+		t.Errorf("Expected profile to be stored as JSON")
+	}
+}
+
+func TestSqlSelectQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		dialect    SqlDialect
+		opts       query.QueryOptions
+		cursorID   string
+		cursor     interface{}
+		backwards  bool
+		softDelete string
+		wantSql    string
+		wantArgs   []interface{}
+		wantErr    bool
+	}{
+		{
+			name:     "filters postgres",
+			dialect:  PostgresDialect,
+			opts:     query.QueryOptions{Limit: 10, Filters: []query.Filter{{Field: "email", Operator: query.Eq, Value: "a"}, {Field: "age", Operator: query.Gte, Value: 18}}},
+			wantSql:  `SELECT "id", "email", "full_name", "age", "profile", "created_at" FROM "users" WHERE "email" = $1 AND "age" >= $2 ORDER BY "id" ASC LIMIT 10`,
+			wantArgs: []interface{}{"a", 18},
+		},
+		{
+			name:     "contains sqlite",
+			dialect:  SqliteDialect,
+			opts:     query.QueryOptions{Limit: 5, Filters: []query.Filter{{Field: "name", Operator: query.Contains, Value: []string{"a", "b"}}}},
+			wantSql:  `SELECT "id", "email", "full_name", "age", "profile", "created_at" FROM "users" WHERE "full_name" IN (?, ?) ORDER BY "id" ASC LIMIT 5`,
+			wantArgs: []interface{}{"a", "b"},
+		},
+		{
+			name:     "null mysql",
+			dialect:  MysqlDialect,
+			opts:     query.QueryOptions{Limit: 5, Filters: []query.Filter{{Field: "email", Operator: query.Eq, Value: nil}}},
+			wantSql:  "SELECT `id`, `email`, `full_name`, `age`, `profile`, `created_at` FROM `users` WHERE `email` IS NULL ORDER BY `id` ASC LIMIT 5",
+			wantArgs: nil,
+		},
+		{
+			name:     "next page ordered desc",
+			dialect:  PostgresDialect,
+			opts:     query.QueryOptions{Limit: 2, OrderBy: "age", OrderByDirection: query.Desc},
+			cursorID: "abc",
+			cursor:   30,
+			wantSql:  `SELECT "id", "email", "full_name", "age", "profile", "created_at" FROM "users" WHERE ("age" < $1 OR ("age" = $2 AND "id" < $3)) ORDER BY "age" DESC, "id" DESC LIMIT 2`,
+			wantArgs: []interface{}{30, 30, "abc"},
+		},
+		{
+			name:     "next page after null postgres",
+			dialect:  PostgresDialect,
+			opts:     query.QueryOptions{Limit: 2, OrderBy: "age"},
+			cursorID: "abc",
+			wantSql:  `SELECT "id", "email", "full_name", "age", "profile", "created_at" FROM "users" WHERE ("age" IS NULL AND "id" > $1) ORDER BY "age" ASC, "id" ASC LIMIT 2`,
+			wantArgs: []interface{}{"abc"},
+		},
+		{
+			name:     "next page after null sqlite",
+			dialect:  SqliteDialect,
+			opts:     query.QueryOptions{Limit: 2, OrderBy: "age"},
+			cursorID: "abc",
+			wantSql:  `SELECT "id", "email", "full_name", "age", "profile", "created_at" FROM "users" WHERE ("age" IS NULL AND "id" > ? OR "age" IS NOT NULL) ORDER BY "age" ASC, "id" ASC LIMIT 2`,
+			wantArgs: []interface{}{"abc"},
+		},
+		{
+			name:     "next page before nulls sqlite desc",
+			dialect:  SqliteDialect,
+			opts:     query.QueryOptions{Limit: 2, OrderBy: "age", OrderByDirection: query.Desc},
+			cursorID: "abc",
+			cursor:   30,
+			wantSql:  `SELECT "id", "email", "full_name", "age", "profile", "created_at" FROM "users" WHERE ("age" < ? OR ("age" = ? AND "id" < ?) OR "age" IS NULL) ORDER BY "age" DESC, "id" DESC LIMIT 2`,
+			wantArgs: []interface{}{30, 30, "abc"},
+		},
+		{
+			name:      "previous page ordered by id",
+			dialect:   SqliteDialect,
+			opts:      query.QueryOptions{Limit: 2, OrderBy: "id", OrderByDirection: query.Asc},
+			cursorID:  "abc",
+			backwards: true,
+			wantSql:   `SELECT "id", "email", "full_name", "age", "profile", "created_at" FROM "users" WHERE "id" < ? ORDER BY "id" DESC LIMIT 2`,
+			wantArgs:  []interface{}{"abc"},
+		},
+		{
+			name:       "soft delete",
+			dialect:    SqliteDialect,
+			opts:       query.QueryOptions{Limit: 5, Filters: []query.Filter{{Field: "age", Operator: query.Lt, Value: 3}}},
+			softDelete: "deleted_at",
+			wantSql:    `SELECT "id", "email", "full_name", "age", "profile", "created_at" FROM "users" WHERE "age" < ? AND "deleted_at" IS NULL ORDER BY "id" ASC LIMIT 5`,
+			wantArgs:   []interface{}{3},
+		},
+		{
+			name:       "soft delete include deleted",
+			dialect:    SqliteDialect,
+			opts:       query.QueryOptions{Limit: 5, IncludeDeleted: true},
+			softDelete: "deleted_at",
+			wantSql:    `SELECT "id", "email", "full_name", "age", "profile", "created_at" FROM "users" ORDER BY "id" ASC LIMIT 5`,
+		},
+		{
+			name:    "unknown field",
+			dialect: SqliteDialect,
+			opts:    query.QueryOptions{Filters: []query.Filter{{Field: "password; DROP TABLE users", Operator: query.Eq, Value: 1}}},
+			wantErr: true,
+		},
+		{
+			name:    "array contains",
+			dialect: SqliteDialect,
+			opts:    query.QueryOptions{Filters: []query.Filter{{Field: "email", Operator: query.ArrayContains, Value: "a"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := newSqlTable("users", tt.dialect, reflect.TypeOf(&testSqlUser{}))
+			table.SoftDelete = tt.softDelete
+			stmt, args, err := table.selectQuery(&tt.opts, tt.cursorID, tt.cursor, tt.backwards)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if stmt != tt.wantSql {
+				t.Errorf("Expected\n%s\ngot\n%s", tt.wantSql, stmt)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("Expected args %v, got %v", tt.wantArgs, args)
+			}
+		})
+	}
+}
+
+func TestSqlInsertAndUpdateQuery(t *testing.T) {
+	table := newSqlTable("users", PostgresDialect, reflect.TypeOf(&testSqlUser{}))
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := testSqlUser{Email: "a", Name: "A", Age: 3, Profile: testSqlProfile{City: "Berlin"}, CreatedAt: created}
+
+	stmt, args, err := table.insertQuery("id1", reflect.ValueOf(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSql := `INSERT INTO "users" ("id", "email", "full_name", "age", "profile", "created_at") VALUES ($1, $2, $3, $4, $5, $6)`
+	if stmt != wantSql {
+		t.Errorf("Expected %s, got %s", wantSql, stmt)
+	}
+	wantArgs := []interface{}{"id1", "a", "A", 3, `{"city":"Berlin"}`, created}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("Expected args %v, got %v", wantArgs, args)
+	}
+
+	stmt, args, err = table.updateQuery("id1", map[string]interface{}{"name": "B", "age": 4}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSql = `UPDATE "users" SET "age" = $1, "full_name" = $2 WHERE "id" = $3`
+	if stmt != wantSql {
+		t.Errorf("Expected %s, got %s", wantSql, stmt)
+	}
+	if !reflect.DeepEqual(args, []interface{}{4, "B", "id1"}) {
+		t.Errorf("Unexpected args %v", args)
+	}
+
+	if _, _, err := table.updateQuery("id1", map[string]interface{}{"secret": "x"}, nil); err == nil {
+		t.Errorf("Expected error for a field without column")
+	}
+	expected := int64(1)
+	if _, _, err := table.updateQuery("id1", map[string]interface{}{"name": "B"}, &expected); err == nil {
+		t.Errorf("Expected versioned update to require a Version field")
+	}
+
+	versioned := newSqlTable("users", SqliteDialect, reflect.TypeOf(&testVersionedUser{}))
+	stmt, args, err = versioned.updateQuery("id1", map[string]interface{}{"name": "B"}, &expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSql = `UPDATE "users" SET "name" = ?, "version" = "version" + 1 WHERE "id" = ? AND "version" = ?`
+	if stmt != wantSql {
+		t.Errorf("Expected %s, got %s", wantSql, stmt)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"B", "id1", int64(1)}) {
+		t.Errorf("Unexpected args %v", args)
+	}
+}
+
+func TestSqlFieldScanner(t *testing.T) {
+	var user testSqlUser
+	val := reflect.ValueOf(&user).Elem()
+
+	scans := []struct {
+		field string
+		src   interface{}
+	}{
+		{"Name", []byte("A")},
+		{"Age", int64(7)},
+		{"Profile", `{"city":"Hamburg"}`},
+		{"CreatedAt", "2024-01-02T03:04:05Z"},
+		{"Email", nil},
+	}
+	for _, s := range scans {
+		f, _ := val.Type().FieldByName(s.field)
+		scanner := &fieldScanner{dst: val.FieldByName(s.field), json: isJSONType(f.Type)}
+		if err := scanner.Scan(s.src); err != nil {
+			t.Fatalf("Scan %s: %v", s.field, err)
+		}
+	}
+
+	if user.Name != "A" || user.Age != 7 || user.Profile.City != "Hamburg" || user.Email != "" {
+		t.Errorf("Unexpected scan result %+v", user)
+	}
+	if !user.CreatedAt.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Unexpected CreatedAt %v", user.CreatedAt)
+	}
+}
+
+type testSqlStateError struct{}
+
+func (testSqlStateError) Error() string    { return "constraint" }
+func (testSqlStateError) SQLState() string { return "23505" }
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("UNIQUE constraint failed: users.email"), true},
+		{fmt.Errorf(`pq: duplicate key value violates unique constraint "users_email_key"`), true},
+		{fmt.Errorf("Error 1062: Duplicate entry 'a' for key 'email'"), true},
+		{fmt.Errorf("wrapped: %w", testSqlStateError{}), true},
+		{fmt.Errorf("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := isUniqueViolation(tt.err); got != tt.want {
+			t.Errorf("isUniqueViolation(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestSqlUnknownFieldsAreBadRequests(t *testing.T) {
+	table := newSqlTable("users", SqliteDialect, reflect.TypeOf(&testSqlUser{}))
+	for _, opts := range []query.QueryOptions{
+		{Filters: []query.Filter{{Field: "password", Operator: query.Eq, Value: "x"}}},
+		{Filters: []query.Filter{{Field: "email", Operator: query.Contains, Value: "a"}}},
+		{OrderBy: "password"},
+	} {
+		if _, _, err := table.selectQuery(&opts, "", nil, false); !isBadRequest(err) {
+			t.Errorf("Expected ErrorBadRequest for %+v, got %v", opts, err)
+		}
+	}
+}
+
+func isBadRequest(err error) bool {
+	_, ok := err.(*errors.ErrorBadRequest)
+	return ok
+}
@@ -0,0 +1,417 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+
+	"cloud.google.com/go/firestore"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+type tenantKey struct{}
+
+// ContextWithTenant returns a copy of ctx carrying the tenant used by tenant repositories.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set with ContextWithTenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+type tenantRepository[T Entity, TT any] struct {
+	repo      Repository[T, TT]
+	Ressource string
+	Field     string
+}
+
+// NewTenantRepository scopes repo to the tenant in the context of every call. field is the
+// Firestore name of the entity's tenant field: Get and All only return documents of the tenant,
+// creates stamp it, and documents of other tenants are reported as not found. Calls without a
+// tenant fail with ErrorForbidden.
+//
+// Uniqueness is per tenant: writes checking UniqFields fail with ErrorForbidden unless the tenant
+// field is part of them and, with WithUniqueConstraints, of WithUniqueScope of the wrapped
+// repository. A duplicate therefore never reveals values of other tenants.
+//
+// Ownership of an existing document is checked with a read before the write, not atomically with
+// it: a document moved to another tenant through the unscoped repository in between may still be
+// written. Inside WithTransaction the check and the write share one Firestore transaction, which
+// closes this gap.
+func NewTenantRepository[T Entity, TT any](repo Repository[T, TT], ressource string, field string) Repository[T, TT] {
+	return &tenantRepository[T, TT]{
+		repo:      repo,
+		Ressource: ressource,
+		Field:     field,
+	}
+}
+
+func (r *tenantRepository[T, TT]) GetClient() *firestore.Client {
+	return r.repo.GetClient()
+}
+
+func (r *tenantRepository[T, TT]) Get(ctx context.Context, opts *query.QueryOptions) (*PaginationResult[T], error) {
+	if opts == nil {
+		opts = &query.QueryOptions{
+			Limit: 100,
+		}
+	}
+	scoped, err := r.scoped(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.repo.Get(ctx, scoped)
+	if err != nil {
+		return nil, err
+	}
+	res.Filters = &opts.Filters
+	return res, nil
+}
+
+func (r *tenantRepository[T, TT]) Count(ctx context.Context, opts *query.QueryOptions) (int64, error) {
+	scoped, err := r.scoped(ctx, opts)
+	if err != nil {
+		return 0, err
+	}
+	return r.repo.Count(ctx, scoped)
+}
+
+func (r *tenantRepository[T, TT]) Aggregate(ctx context.Context, opts *query.QueryOptions, agg Aggregation) (*AggregationResult, error) {
+	scoped, err := r.scoped(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.repo.Aggregate(ctx, scoped, agg)
+}
+
+func (r *tenantRepository[T, TT]) All(ctx context.Context, opts *query.QueryOptions) iter.Seq2[T, error] {
+	return allPages(ctx, r.Get, opts)
+}
+
+func (r *tenantRepository[T, TT]) GetByID(ctx context.Context, id string) (*T, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := r.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !r.belongsTo(*obj, tenant) {
+		return nil, r.notFound(id)
+	}
+	return obj, nil
+}
+
+// GetByIDs reports the IDs of other tenants as missing.
+func (r *tenantRepository[T, TT]) GetByIDs(ctx context.Context, ids []string) ([]T, []string, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	objs, _, err := r.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	found := make(map[string]T, len(objs))
+	for _, obj := range objs {
+		if r.belongsTo(obj, tenant) {
+			found[obj.DocId()] = obj
+		}
+	}
+	unique, _ := uniqueIDs(ids)
+	objs, missing := inOrder(unique, found)
+	return objs, missing, nil
+}
+
+func (r *tenantRepository[T, TT]) Create(ctx context.Context, obj T) (*string, error) {
+	if err := r.stamp(ctx, obj); err != nil {
+		return nil, err
+	}
+	if err := r.checkUniqueScope(obj.UniqFields()); err != nil {
+		return nil, err
+	}
+	return r.repo.Create(ctx, obj)
+}
+
+func (r *tenantRepository[T, TT]) CreateEasy(ctx context.Context, obj T) (*string, error) {
+	if err := r.stamp(ctx, obj); err != nil {
+		return nil, err
+	}
+	return r.repo.CreateEasy(ctx, obj)
+}
+
+// Upsert stamps obj and requires the tenant field among its UniqFields, so that only a document of
+// the tenant can match.
+func (r *tenantRepository[T, TT]) Upsert(ctx context.Context, obj T) (*string, bool, error) {
+	if err := r.stamp(ctx, obj); err != nil {
+		return nil, false, err
+	}
+	if err := r.checkUniqueScope(obj.UniqFields()); err != nil {
+		return nil, false, err
+	}
+	return r.repo.Upsert(ctx, obj)
+}
+
+// CreateQueryNotExists stamps obj and limits the existence query to the tenant.
+func (r *tenantRepository[T, TT]) CreateQueryNotExists(ctx context.Context, obj T, funcQuery func(firestore.Query) firestore.Query) (*string, error) {
+	if err := r.stamp(ctx, obj); err != nil {
+		return nil, err
+	}
+	tenant, _ := TenantFromContext(ctx)
+	return r.repo.CreateQueryNotExists(ctx, obj, func(q firestore.Query) firestore.Query {
+		return funcQuery(q).Where(r.Field, "==", tenant)
+	})
+}
+
+func (r *tenantRepository[T, TT]) Update(ctx context.Context, id string, data map[string]interface{}) error {
+	if err := r.checkUpdate(ctx, id, data); err != nil {
+		return err
+	}
+	return r.repo.Update(ctx, id, data)
+}
+
+func (r *tenantRepository[T, TT]) UpdateWithVersion(ctx context.Context, id string, version string, data map[string]interface{}) error {
+	if err := r.checkUpdate(ctx, id, data); err != nil {
+		return err
+	}
+	return r.repo.UpdateWithVersion(ctx, id, version, data)
+}
+
+func (r *tenantRepository[T, TT]) Patch(ctx context.Context, id string, patch interface{}, mask ...string) error {
+	data, err := patchData(r.Ressource, reflect.TypeOf((*T)(nil)).Elem(), patch, mask)
+	if err != nil {
+		return err
+	}
+	if err := r.checkUpdate(ctx, id, data); err != nil {
+		return err
+	}
+	return r.repo.Patch(ctx, id, patch, mask...)
+}
+
+func (r *tenantRepository[T, TT]) Delete(ctx context.Context, id string) error {
+	if err := r.checkOwner(ctx, id); err != nil {
+		return err
+	}
+	return r.repo.Delete(ctx, id)
+}
+
+func (r *tenantRepository[T, TT]) Restore(ctx context.Context, id string) error {
+	if err := r.checkOwner(ctx, id); err != nil {
+		return err
+	}
+	if err := r.checkUniqueScope(newEntity[T]().UniqFields()); err != nil {
+		return err
+	}
+	return r.repo.Restore(ctx, id)
+}
+
+func (r *tenantRepository[T, TT]) Purge(ctx context.Context, id string) error {
+	if err := r.checkOwner(ctx, id); err != nil {
+		return err
+	}
+	return r.repo.Purge(ctx, id)
+}
+
+func (r *tenantRepository[T, TT]) History(ctx context.Context, id string, opts *query.QueryOptions) (*PaginationResult[*AuditRecord], error) {
+	if err := r.checkOwner(ctx, id); err != nil {
+		return nil, err
+	}
+	return r.repo.History(ctx, id, opts)
+}
+
+// Watch only streams documents of the tenant.
+func (r *tenantRepository[T, TT]) Watch(ctx context.Context, opts *query.QueryOptions) iter.Seq2[WatchEvent[T], error] {
+	scoped, err := r.scoped(ctx, opts)
+	if err != nil {
+		return func(yield func(WatchEvent[T], error) bool) {
+			yield(WatchEvent[T]{}, err)
+		}
+	}
+	return r.repo.Watch(ctx, scoped)
+}
+
+func (r *tenantRepository[T, TT]) CreateMany(ctx context.Context, objs []T) ([]BulkResult, error) {
+	return scopedBulk(len(objs), func(i int) (string, error) {
+		if err := r.stamp(ctx, objs[i]); err != nil {
+			return "", err
+		}
+		return "", r.checkUniqueScope(objs[i].UniqFields())
+	}, func(indexes []int) ([]BulkResult, error) {
+		subset := make([]T, len(indexes))
+		for j, i := range indexes {
+			subset[j] = objs[i]
+		}
+		return r.repo.CreateMany(ctx, subset)
+	})
+}
+
+func (r *tenantRepository[T, TT]) UpdateMany(ctx context.Context, updates []BulkUpdate) ([]BulkResult, error) {
+	return scopedBulk(len(updates), func(i int) (string, error) {
+		return updates[i].ID, r.checkUpdate(ctx, updates[i].ID, updates[i].Data)
+	}, func(indexes []int) ([]BulkResult, error) {
+		subset := make([]BulkUpdate, len(indexes))
+		for j, i := range indexes {
+			subset[j] = updates[i]
+		}
+		return r.repo.UpdateMany(ctx, subset)
+	})
+}
+
+func (r *tenantRepository[T, TT]) DeleteMany(ctx context.Context, ids []string) ([]BulkResult, error) {
+	return scopedBulk(len(ids), func(i int) (string, error) {
+		return ids[i], r.checkOwner(ctx, ids[i])
+	}, func(indexes []int) ([]BulkResult, error) {
+		subset := make([]string, len(indexes))
+		for j, i := range indexes {
+			subset[j] = ids[i]
+		}
+		return r.repo.DeleteMany(ctx, subset)
+	})
+}
+
+// scopedBulk runs check for every item and bulk for those that passed. The results of both are
+// merged in input order.
+func scopedBulk(n int, check func(i int) (string, error), bulk func(indexes []int) ([]BulkResult, error)) ([]BulkResult, error) {
+	results := bulkEach(n, defaultBulkConcurrency, check)
+	passed := make([]int, 0, n)
+	for i, res := range results {
+		if res.Err == nil {
+			passed = append(passed, i)
+		}
+	}
+	if len(passed) == 0 {
+		return results, nil
+	}
+
+	bulkResults, err := bulk(passed)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range passed {
+		results[i] = bulkResults[j]
+	}
+	return results, nil
+}
+
+func (r *tenantRepository[T, TT]) tenant(ctx context.Context) (string, error) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return "", r.forbidden("no tenant in context")
+	}
+	return tenant, nil
+}
+
+// scoped returns a copy of opts with an additional filter on the tenant of ctx.
+func (r *tenantRepository[T, TT]) scoped(ctx context.Context, opts *query.QueryOptions) (*query.QueryOptions, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &query.QueryOptions{}
+	}
+
+	scoped := *opts
+	scoped.Filters = append(slices.Clone(opts.Filters), query.Filter{Field: r.Field, Operator: query.Eq, Value: tenant})
+	return &scoped, nil
+}
+
+// stamp sets the tenant field of obj. An entity already assigned to another tenant is rejected.
+func (r *tenantRepository[T, TT]) stamp(ctx context.Context, obj T) error {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	if value, ok := lookupPath(reflect.ValueOf(obj), r.Field); ok && !value.IsZero() && fmt.Sprint(value.Interface()) != tenant {
+		return r.forbidden(r.Ressource + " belongs to another tenant")
+	}
+	return setPath(reflect.ValueOf(&obj).Elem(), r.Field, tenant)
+}
+
+// checkUpdate fails unless id belongs to the tenant and data leaves the tenant field alone.
+func (r *tenantRepository[T, TT]) checkUpdate(ctx context.Context, id string, data map[string]interface{}) error {
+	if touchesUnique(map[string]interface{}{r.Field: nil}, data) {
+		return r.forbidden("the tenant of " + r.Ressource + " cannot be changed")
+	}
+	if uniq := newEntity[T]().UniqFields(); touchesUnique(uniq, data) {
+		if err := r.checkUniqueScope(uniq); err != nil {
+			return err
+		}
+	}
+	return r.checkOwner(ctx, id)
+}
+
+// checkUniqueScope fails unless a uniqueness check on uniq is limited to the tenant: the tenant
+// field must be one of the unique fields and, with WithUniqueConstraints, a scope field, as it
+// would otherwise be unique on its own.
+func (r *tenantRepository[T, TT]) checkUniqueScope(uniq map[string]interface{}) error {
+	if len(uniq) == 0 {
+		return nil
+	}
+	if _, ok := uniq[r.Field]; !ok {
+		return r.forbidden("uniqueness of " + r.Ressource + " must be scoped to the tenant, add " + r.Field + " to its UniqFields")
+	}
+	if o := configurationOf(r.repo); o != nil && o.uniqueConstraints && !slices.Contains(o.uniqueScope, r.Field) {
+		return r.forbidden("uniqueness of " + r.Ressource + " must be scoped to the tenant, pass WithUniqueScope(\"" + r.Field + "\")")
+	}
+	return nil
+}
+
+// checkOwner fails with ErrorNotFound unless id exists for the tenant, soft deleted or not.
+func (r *tenantRepository[T, TT]) checkOwner(ctx context.Context, id string) error {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return errIDRequired
+	}
+	page, err := r.repo.Get(ctx, &query.QueryOptions{
+		Limit:          1,
+		IncludeDeleted: true,
+		Filters: []query.Filter{
+			{Field: "id", Operator: query.Eq, Value: id},
+			{Field: r.Field, Operator: query.Eq, Value: tenant},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if len(page.Items) == 0 {
+		return r.notFound(id)
+	}
+	return nil
+}
+
+func (r *tenantRepository[T, TT]) belongsTo(obj T, tenant string) bool {
+	value, ok := lookupPath(reflect.ValueOf(obj), r.Field)
+	return ok && fmt.Sprint(value.Interface()) == tenant
+}
+
+func (r *tenantRepository[T, TT]) notFound(id string) error {
+	return &errors.ErrorNotFound{
+		ErrorDetail: errors.ErrorDetail{
+			Resource: r.Ressource,
+			Field:    "id",
+			Value:    id,
+			Message:  r.Ressource + " with id " + id + " not found",
+		},
+	}
+}
+
+func (r *tenantRepository[T, TT]) forbidden(message string) error {
+	return &errors.ErrorForbidden{
+		ErrorDetail: errors.ErrorDetail{
+			Resource: r.Ressource,
+			Field:    r.Field,
+			Message:  message,
+		},
+	}
+}
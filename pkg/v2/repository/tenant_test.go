@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+type testTenantUser struct {
+	testUser
+	TenantID string `firestore:"tenant_id"`
+}
+
+func (u *testTenantUser) UniqFields() map[string]interface{} {
+	return map[string]interface{}{
+		"tenant_id": u.TenantID,
+		"email":     u.Email,
+	}
+}
+
+func TestTenantRepository(t *testing.T) {
+	inner := NewMemoryRepository[*testTenantUser, testTenantUser]("User", WithSoftDelete())
+	repo := NewTenantRepository[*testTenantUser, testTenantUser](inner, "User", "tenant_id")
+	a := ContextWithTenant(context.Background(), "a")
+	b := ContextWithTenant(context.Background(), "b")
+
+	user := &testTenantUser{testUser: testUser{Email: "x@example.com"}}
+	id, err := repo.Create(a, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.TenantID != "a" {
+		t.Errorf("Expected Create to stamp the tenant, got %q", user.TenantID)
+	}
+	if _, err := repo.Create(b, &testTenantUser{testUser: testUser{Email: "x@example.com"}}); err != nil {
+		t.Errorf("Expected unique values to be scoped per tenant, got %v", err)
+	}
+	if _, err := repo.Create(a, &testTenantUser{testUser: testUser{Email: "x@example.com"}}); err == nil {
+		t.Errorf("Expected duplicate within a tenant to be rejected")
+	}
+	if _, err := repo.Create(a, &testTenantUser{testUser: testUser{Email: "y@example.com"}, TenantID: "b"}); !isForbidden(err) {
+		t.Errorf("Expected entity of another tenant to be rejected")
+	}
+
+	page, err := repo.Get(b, &query.QueryOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].TenantID != "b" || len(*page.Filters) != 0 {
+		t.Errorf("Expected only the tenant's documents without the injected filter, got %+v", page)
+	}
+
+	if _, err := repo.GetByID(b, *id); !isNotFound(err) {
+		t.Errorf("Expected GetByID on another tenant to be not found, got %v", err)
+	}
+	if err := repo.Update(b, *id, map[string]interface{}{"name": "B"}); !isNotFound(err) {
+		t.Errorf("Expected Update on another tenant to be not found, got %v", err)
+	}
+	if err := repo.Delete(b, *id); !isNotFound(err) {
+		t.Errorf("Expected Delete on another tenant to be not found, got %v", err)
+	}
+	if err := repo.Update(a, *id, map[string]interface{}{"tenant_id": "b"}); !isForbidden(err) {
+		t.Errorf("Expected the tenant field to be immutable")
+	}
+	if _, err := repo.GetByID(context.Background(), *id); err == nil {
+		t.Errorf("Expected calls without tenant to fail")
+	}
+
+	if err := repo.Delete(a, *id); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Restore(a, *id); err != nil {
+		t.Errorf("Expected Restore to find the tenant's deleted document, got %v", err)
+	}
+
+	results, err := repo.DeleteMany(b, []string{*id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isNotFound(results[0].Err) {
+		t.Errorf("Expected DeleteMany to skip documents of other tenants, got %v", results[0].Err)
+	}
+}
+
+func isNotFound(err error) bool {
+	_, ok := err.(*errors.ErrorNotFound)
+	return ok
+}
+
+func isForbidden(err error) bool {
+	_, ok := err.(*errors.ErrorForbidden)
+	return ok
+}
+
+type testTenantAccount struct {
+	testUser
+	TenantID string `firestore:"tenant_id"`
+}
+
+func TestTenantRepositoryUniqueScope(t *testing.T) {
+	a := ContextWithTenant(context.Background(), "a")
+	b := ContextWithTenant(context.Background(), "b")
+
+	// testTenantAccount is unique by email alone, which would reveal the emails of other tenants.
+	global := NewTenantRepository[*testTenantAccount, testTenantAccount](NewMemoryRepository[*testTenantAccount, testTenantAccount]("Account"), "Account", "tenant_id")
+	if _, err := global.Create(a, &testTenantAccount{testUser: testUser{Email: "x@example.com"}}); !isForbidden(err) {
+		t.Errorf("Expected ErrorForbidden without the tenant field in UniqFields, got %v", err)
+	}
+
+	unscoped := NewTenantRepository[*testTenantUser, testTenantUser](
+		NewMemoryRepository[*testTenantUser, testTenantUser]("User", WithUniqueConstraints()), "User", "tenant_id")
+	if _, err := unscoped.Create(a, &testTenantUser{testUser: testUser{Email: "x@example.com"}}); !isForbidden(err) {
+		t.Errorf("Expected ErrorForbidden for unique constraints without the tenant scope, got %v", err)
+	}
+
+	for name, inner := range map[string]Repository[*testTenantUser, testTenantUser]{
+		"combined":    NewMemoryRepository[*testTenantUser, testTenantUser]("User"),
+		"constraints": NewMemoryRepository[*testTenantUser, testTenantUser]("User", WithUniqueConstraints(), WithUniqueScope("tenant_id")),
+		"cached":      NewCachedRepository[*testTenantUser, testTenantUser](Repository[*testTenantUser, testTenantUser](NewMemoryRepository[*testTenantUser, testTenantUser]("User", WithUniqueConstraints(), WithUniqueScope("tenant_id"))), NewLRUCache(10, time.Minute)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo := NewTenantRepository[*testTenantUser, testTenantUser](inner, "User", "tenant_id")
+			if _, err := repo.Create(a, &testTenantUser{testUser: testUser{Email: "x@example.com"}}); err != nil {
+				t.Fatal(err)
+			}
+			id, err := repo.Create(b, &testTenantUser{testUser: testUser{Email: "x@example.com"}})
+			if err != nil {
+				t.Fatalf("Expected the same email in another tenant, got %v", err)
+			}
+			if _, err := repo.Create(b, &testTenantUser{testUser: testUser{Email: "x@example.com"}}); err == nil {
+				t.Error("Expected a duplicate within the tenant to be rejected")
+			}
+			if _, err := repo.Create(b, &testTenantUser{testUser: testUser{Email: "y@example.com"}}); err != nil {
+				t.Fatalf("Expected a second entity of the tenant, got %v", err)
+			}
+			if err := repo.Update(b, *id, map[string]interface{}{"email": "y@example.com"}); err == nil {
+				t.Error("Expected an update to a duplicate within the tenant to be rejected")
+			}
+		})
+	}
+}
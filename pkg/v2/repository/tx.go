@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unitOfWork is a Firestore transaction shared by the repository calls of a WithTransaction
+// function. Its writes are collected and applied after the function returns, so that later
+// calls can still read.
+type unitOfWork struct {
+	db *firestore.Client
+	tx *firestore.Transaction
+
+	mu        sync.Mutex
+	writes    []func() error
+	committed []func()
+	aborted   error
+}
+
+type unitOfWorkKey struct{}
+
+// WithTransaction runs f in a Firestore transaction on db. Every Firestore repository of db
+// called with the context passed to f joins the transaction: reads happen immediately, writes
+// are applied when f returns and are committed atomically. If f fails, nothing is written.
+//
+// Reads do not see writes made earlier in the same transaction. Firestore retries the whole
+// transaction when it aborts due to contention, so f may run several times and must not have
+// side effects outside the repositories. Calls within f must not run concurrently. A nested
+// WithTransaction joins the outer transaction.
+func WithTransaction(ctx context.Context, db *firestore.Client, f func(ctx context.Context) error, opts ...firestore.TransactionOption) error {
+	if _, ok := transactionFromContext(ctx); ok {
+		return f(ctx)
+	}
+	var uow *unitOfWork
+	err := db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		uow = &unitOfWork{db: db, tx: tx}
+		if err := f(context.WithValue(ctx, unitOfWorkKey{}, uow)); err != nil {
+			// Repositories translate errors, so the status Firestore needs to retry is kept aside.
+			if uow.aborted != nil {
+				return uow.aborted
+			}
+			return err
+		}
+		return uow.flush()
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	for _, f := range uow.committed {
+		f()
+	}
+	return nil
+}
+
+func transactionFromContext(ctx context.Context) (*unitOfWork, bool) {
+	uow, ok := ctx.Value(unitOfWorkKey{}).(*unitOfWork)
+	return uow, ok
+}
+
+// inTransaction reports whether ctx belongs to a WithTransaction call.
+func inTransaction(ctx context.Context) bool {
+	_, ok := transactionFromContext(ctx)
+	return ok
+}
+
+// observe keeps an Aborted error of a read, so that WithTransaction can have Firestore retry.
+func (u *unitOfWork) observe(err error) error {
+	if status.Code(err) == codes.Aborted {
+		u.mu.Lock()
+		u.aborted = err
+		u.mu.Unlock()
+	}
+	return err
+}
+
+func (u *unitOfWork) write(w func() error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.writes = append(u.writes, w)
+}
+
+// onCommit registers f to run after the unit of work has been committed.
+func (u *unitOfWork) onCommit(f func()) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.committed = append(u.committed, f)
+}
+
+func (u *unitOfWork) flush() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, w := range u.writes {
+		if err := w(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// txn is the transaction a repository method runs in. Inside a unit of work its writes are
+// deferred until the unit of work ends.
+type txn struct {
+	*firestore.Transaction
+	uow *unitOfWork
+}
+
+func (t *txn) Create(dr *firestore.DocumentRef, data interface{}) error {
+	return t.write(func() error { return t.Transaction.Create(dr, data) })
+}
+
+func (t *txn) Set(dr *firestore.DocumentRef, data interface{}, opts ...firestore.SetOption) error {
+	return t.write(func() error { return t.Transaction.Set(dr, data, opts...) })
+}
+
+func (t *txn) Update(dr *firestore.DocumentRef, data []firestore.Update, opts ...firestore.Precondition) error {
+	return t.write(func() error { return t.Transaction.Update(dr, data, opts...) })
+}
+
+func (t *txn) Delete(dr *firestore.DocumentRef, opts ...firestore.Precondition) error {
+	return t.write(func() error { return t.Transaction.Delete(dr, opts...) })
+}
+
+func (t *txn) write(w func() error) error {
+	if t.uow == nil {
+		return w()
+	}
+	t.uow.write(w)
+	return nil
+}
+
+// runTransaction runs f in the unit of work of ctx or, outside of one, in a new transaction.
+func (r *repository[T, TT]) runTransaction(ctx context.Context, f func(ctx context.Context, tx *txn) error) error {
+	if uow, ok := transactionFromContext(ctx); ok {
+		if uow.db != r.Db {
+			return fmt.Errorf("%s: the transaction belongs to another Firestore client", r.Ressource)
+		}
+		return uow.observe(f(ctx, &txn{Transaction: uow.tx, uow: uow}))
+	}
+	return r.Db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		return f(ctx, &txn{Transaction: tx})
+	})
+}
+
+// bulkConcurrency is the concurrency of bulk operations, which run one at a time in a unit of work.
+func (r *repository[T, TT]) bulkConcurrency(ctx context.Context) int {
+	if inTransaction(ctx) {
+		return 1
+	}
+	return r.opts.bulkConcurrency
+}
+
+// unitOfWork returns the unit of work of ctx if it runs on the client of r.
+func (r *repository[T, TT]) unitOfWork(ctx context.Context) (*unitOfWork, bool) {
+	uow, ok := transactionFromContext(ctx)
+	return uow, ok && uow.db == r.Db
+}
+
+// getAll runs q in the unit of work of ctx, if any.
+func (r *repository[T, TT]) getAll(ctx context.Context, q firestore.Query) ([]*firestore.DocumentSnapshot, error) {
+	if uow, ok := r.unitOfWork(ctx); ok {
+		docs, err := uow.tx.Documents(q).GetAll()
+		return docs, uow.observe(err)
+	}
+	return q.Documents(ctx).GetAll()
+}
+
+// getDoc reads ref in the unit of work of ctx, if any.
+func (r *repository[T, TT]) getDoc(ctx context.Context, ref *firestore.DocumentRef) (*firestore.DocumentSnapshot, error) {
+	if uow, ok := r.unitOfWork(ctx); ok {
+		doc, err := uow.tx.Get(ref)
+		return doc, uow.observe(err)
+	}
+	return ref.Get(ctx)
+}
+
+// getDocs reads refs in the unit of work of ctx, if any.
+func (r *repository[T, TT]) getDocs(ctx context.Context, refs []*firestore.DocumentRef) ([]*firestore.DocumentSnapshot, error) {
+	if uow, ok := r.unitOfWork(ctx); ok {
+		docs, err := uow.tx.GetAll(refs)
+		return docs, uow.observe(err)
+	}
+	return r.Db.GetAll(ctx, refs)
+}
+
+// aggregate runs q in the unit of work of ctx, if any.
+func (r *repository[T, TT]) aggregate(ctx context.Context, q *firestore.AggregationQuery) (firestore.AggregationResult, error) {
+	if uow, ok := r.unitOfWork(ctx); ok {
+		res, err := q.Transaction(uow.tx).Get(ctx)
+		return res, uow.observe(err)
+	}
+	return q.Get(ctx)
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUnitOfWorkDefersWrites(t *testing.T) {
+	uow := &unitOfWork{}
+	tx := &txn{uow: uow}
+
+	var order []int
+	for i := range 3 {
+		if err := tx.write(func() error { order = append(order, i); return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(order) != 0 {
+		t.Fatalf("Expected writes to be deferred, got %v", order)
+	}
+	if err := uow.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 3 || order[0] != 0 || order[2] != 2 {
+		t.Errorf("Expected writes in call order, got %v", order)
+	}
+
+	failed := errors.New("failed")
+	uow = &unitOfWork{}
+	uow.write(func() error { return failed })
+	if err := uow.flush(); !errors.Is(err, failed) {
+		t.Errorf("Expected the write error, got %v", err)
+	}
+
+	written := false
+	if err := (&txn{}).write(func() error { written = true; return nil }); err != nil || !written {
+		t.Errorf("Expected an immediate write outside of a unit of work")
+	}
+}
+
+func TestCachedRepositoryInTransaction(t *testing.T) {
+	repo := NewCachedRepository[*testUser, testUser](NewMemoryRepository[*testUser, testUser]("User"), NewLRUCache(10, time.Minute))
+	id, err := repo.Create(context.Background(), &testUser{Name: "A", Email: "a@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uow := &unitOfWork{}
+	ctx := context.WithValue(context.Background(), unitOfWorkKey{}, uow)
+	if _, err := repo.GetByID(ctx, *id); err != nil {
+		t.Fatal(err)
+	}
+	if stats := repo.Stats(); stats.Hits+stats.Misses != 0 {
+		t.Errorf("Expected reads in a unit of work to bypass the cache, got %+v", stats)
+	}
+
+	if err := repo.Update(ctx, *id, map[string]interface{}{"name": "B"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(uow.committed) != 1 {
+		t.Errorf("Expected the update to evict the entity again after the commit")
+	}
+}
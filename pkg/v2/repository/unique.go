@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+)
+
+// alreadyExists reports that another document holds the given unique values.
+// Several fields are joined with commas, in sorted order.
+func alreadyExists(ressource string, uniq map[string]interface{}) error {
+	fields := sortedKeys(uniq)
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = fmt.Sprint(uniq[field])
+	}
+
+	field, value := strings.Join(fields, ","), strings.Join(values, ",")
+	message := ressource + " already exists"
+	if field != "" {
+		message = ressource + " with " + field + " " + value + " already exists"
+	}
+	return &errors.ErrorAlreadyExists{
+		ErrorDetail: errors.ErrorDetail{
+			Resource: ressource,
+			Field:    field,
+			Value:    value,
+			Message:  message,
+		},
+	}
+}
+
+// touchesUnique reports whether data writes to a unique field, a parent or a child of one.
+func touchesUnique(uniq map[string]interface{}, data map[string]interface{}) bool {
+	for path := range data {
+		if isUniquePath(uniq, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func isUniquePath(uniq map[string]interface{}, path string) bool {
+	for field := range uniq {
+		if field == path || strings.HasPrefix(field, path+".") || strings.HasPrefix(path, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// updatedUniqFields applies the unique parts of data to obj and returns its new unique values.
+// obj is modified, so pass a freshly loaded entity.
+func updatedUniqFields[T Entity](obj T, data map[string]interface{}) (map[string]interface{}, error) {
+	uniq := obj.UniqFields()
+	val := reflect.ValueOf(&obj).Elem()
+	for path, value := range data {
+		if !isUniquePath(uniq, path) {
+			continue
+		}
+		if err := setPath(val, path, value); err != nil {
+			return nil, err
+		}
+	}
+	return obj.UniqFields(), nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isZeroValue(value interface{}) bool {
+	return value == nil || reflect.ValueOf(value).IsZero()
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+)
+
+func TestAlreadyExists(t *testing.T) {
+	tests := []struct {
+		uniq      map[string]interface{}
+		wantField string
+		wantValue string
+		wantError string
+	}{
+		{map[string]interface{}{"email": "a@example.com"}, "email", "a@example.com", "User with email a@example.com already exists"},
+		{map[string]interface{}{"tenant": "t1", "number": 7}, "number,tenant", "7,t1", "User with number,tenant 7,t1 already exists"},
+	}
+	for _, tt := range tests {
+		err := alreadyExists("User", tt.uniq).(*errors.ErrorAlreadyExists)
+		if err.Field != tt.wantField || err.Value != tt.wantValue {
+			t.Errorf("Expected %s=%s, got %s=%s", tt.wantField, tt.wantValue, err.Field, err.Value)
+		}
+		if err.Error() != tt.wantError {
+			t.Errorf("Expected %q, got %q", tt.wantError, err.Error())
+		}
+	}
+}
+
+func TestTouchesUnique(t *testing.T) {
+	uniq := map[string]interface{}{"email": "", "address.city": ""}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"email", true},
+		{"address", true},
+		{"address.city", true},
+		{"email.domain", true},
+		{"emails", false},
+		{"name", false},
+	}
+	for _, tt := range tests {
+		if got := touchesUnique(uniq, map[string]interface{}{tt.path: 1}); got != tt.want {
+			t.Errorf("touchesUnique(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryRepositoryUpdateUnique(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User")
+	ids := seedUsers(t, repo, &testUser{Email: "a@example.com"}, &testUser{Email: "b@example.com"})
+
+	err := repo.Update(ctx, ids[1], map[string]interface{}{"email": "a@example.com"})
+	existsErr, ok := err.(*errors.ErrorAlreadyExists)
+	if !ok {
+		t.Fatalf("Expected ErrorAlreadyExists, got %v", err)
+	}
+	if existsErr.Field != "email" || existsErr.Value != "a@example.com" {
+		t.Errorf("Expected offending field and value, got %s=%s", existsErr.Field, existsErr.Value)
+	}
+	got, _ := repo.GetByID(ctx, ids[1])
+	if (*got).Email != "b@example.com" {
+		t.Errorf("Expected rejected update to leave the document unchanged, got %s", (*got).Email)
+	}
+
+	if err := repo.Update(ctx, ids[0], map[string]interface{}{"email": "a@example.com", "name": "A"}); err != nil {
+		t.Errorf("Expected a document not to conflict with itself, got %v", err)
+	}
+	if _, ok := repo.Patch(ctx, ids[0], &testUser{Email: "b@example.com"}, "Email").(*errors.ErrorAlreadyExists); !ok {
+		t.Errorf("Expected Patch to enforce uniqueness")
+	}
+}
+
+type testAccount struct {
+	testUser
+	Username string `firestore:"username"`
+}
+
+func (a *testAccount) UniqFields() map[string]interface{} {
+	return map[string]interface{}{
+		"email":    a.Email,
+		"username": a.Username,
+	}
+}
+
+func TestUniqueGroups(t *testing.T) {
+	uniq := map[string]interface{}{"username": "a", "email": "a@example.com", "phone": ""}
+
+	if groups := newOptions(nil).uniqueGroups(uniq); len(groups) != 1 || len(groups[0]) != 3 {
+		t.Errorf("Expected all values in one group by default, got %v", groups)
+	}
+	groups := newOptions([]Option{WithUniqueConstraints()}).uniqueGroups(uniq)
+	if len(groups) != 2 || groups[0]["email"] != "a@example.com" || groups[1]["username"] != "a" {
+		t.Errorf("Expected one group per non-zero field, got %v", groups)
+	}
+
+	uniq["tenant"] = "t1"
+	groups = newOptions([]Option{WithUniqueConstraints(), WithUniqueScope("tenant")}).uniqueGroups(uniq)
+	if len(groups) != 2 || groups[0]["tenant"] != "t1" || groups[1]["tenant"] != "t1" || len(groups[1]) != 2 {
+		t.Errorf("Expected the scope in every group and not as its own group, got %v", groups)
+	}
+}
+
+func TestMemoryRepositoryUniqueConstraints(t *testing.T) {
+	ctx := context.Background()
+
+	combined := NewMemoryRepository[*testAccount, testAccount]("Account")
+	if _, err := combined.Create(ctx, &testAccount{testUser: testUser{Email: "a@example.com"}, Username: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := combined.Create(ctx, &testAccount{testUser: testUser{Email: "a@example.com"}, Username: "b"}); err != nil {
+		t.Errorf("Expected combined unique fields to allow a partial collision, got %v", err)
+	}
+
+	repo := NewMemoryRepository[*testAccount, testAccount]("Account", WithUniqueConstraints())
+	id, err := repo.Create(ctx, &testAccount{testUser: testUser{Email: "a@example.com"}, Username: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.Create(ctx, &testAccount{testUser: testUser{Email: "b@example.com"}, Username: "a"})
+	existsErr, ok := err.(*errors.ErrorAlreadyExists)
+	if !ok || existsErr.Field != "username" || existsErr.Value != "a" {
+		t.Errorf("Expected the username constraint to be reported, got %v", err)
+	}
+	if _, err := repo.Create(ctx, &testAccount{testUser: testUser{Email: "c@example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(ctx, &testAccount{testUser: testUser{Email: "d@example.com"}}); err != nil {
+		t.Errorf("Expected empty values not to be reserved, got %v", err)
+	}
+
+	if err := repo.Update(ctx, *id, map[string]interface{}{"username": "z"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(ctx, &testAccount{testUser: testUser{Email: "e@example.com"}, Username: "a"}); err != nil {
+		t.Errorf("Expected the update to release the old username, got %v", err)
+	}
+
+	if err := repo.Delete(ctx, *id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(ctx, &testAccount{testUser: testUser{Email: "a@example.com"}, Username: "z"}); err != nil {
+		t.Errorf("Expected the delete to release the values, got %v", err)
+	}
+}
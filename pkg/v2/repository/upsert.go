@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+var errUniqFieldsRequired = fmt.Errorf("UniqFields are required for Upsert")
+
+// upsertData returns the fields an Upsert writes to the matched document: every persisted field
+// of obj except CreatedAt, the Version field and the soft delete field.
+func (o *options) upsertData(obj interface{}) map[string]interface{} {
+	val, ok := indirect(reflect.ValueOf(obj))
+	if !ok || val.Kind() != reflect.Struct {
+		return nil
+	}
+	vf, versioned := versionField(val.Type())
+	data := map[string]interface{}{}
+	for _, f := range structFields(val.Type()) {
+		if f.GoName == "CreatedAt" || (versioned && f.Name == vf.Name) || (o.softDelete && f.Name == o.softDeleteField) {
+			continue
+		}
+		if fv, err := val.FieldByIndexErr(f.Index); err == nil {
+			data[f.Name] = fv.Interface()
+		}
+	}
+	return data
+}
+
+// prepareUpsert stamps UpdatedAt on obj and takes CreatedAt over from the stored entity, so that
+// obj reflects the document after the update.
+func prepareUpsert(obj, stored interface{}) {
+	setTimestamps(obj, "UpdatedAt")
+	dst, ok := indirect(reflect.ValueOf(obj))
+	if !ok || dst.Kind() != reflect.Struct {
+		return
+	}
+	src, ok := indirect(reflect.ValueOf(stored))
+	if !ok || src.Kind() != reflect.Struct {
+		return
+	}
+	field, value := dst.FieldByName("CreatedAt"), src.FieldByName("CreatedAt")
+	if field.IsValid() && field.CanSet() && field.Type() == timeType && value.IsValid() && value.Type() == timeType {
+		field.Set(value)
+	}
+}
+
+// Upsert creates obj unless a document holds all of its UniqFields, in which case that document
+// is overwritten with the fields of obj, keeping its CreatedAt. It reports whether obj was created.
+// A soft deleted match or several matching documents fail with ErrorAlreadyExists.
+func (r *repository[T, TT]) Upsert(ctx context.Context, obj T) (*string, bool, error) {
+	uniq := obj.UniqFields()
+	if len(uniq) == 0 {
+		return nil, false, errUniqFieldsRequired
+	}
+
+	var docID string
+	var created bool
+	err := r.runTransaction(ctx, func(ctx context.Context, tx *txn) error {
+		documents, err := r.uniqueMatches(tx, uniq)
+		if err != nil {
+			return err
+		}
+		if len(documents) == 0 {
+			if err := beforeCreate(ctx, obj); err != nil {
+				return err
+			}
+			if err := r.opts.validate(obj); err != nil {
+				return err
+			}
+			created = true
+			docID, err = r.createInTx(ctx, tx, obj)
+			return err
+		}
+		doc := documents[0]
+		if len(documents) > 1 || r.isDeleted(doc) {
+			return alreadyExists(r.Ressource, uniq)
+		}
+
+		stored, err := r.decode(ctx, doc)
+		if err != nil {
+			return err
+		}
+		prepareUpsert(obj, *stored)
+		if err := r.opts.validate(obj); err != nil {
+			return err
+		}
+		changes, commit, err := r.prepareUpdate(ctx, tx, doc, r.opts.upsertData(obj))
+		if err != nil {
+			return err
+		}
+		if err := tx.Update(doc.Ref, r.updates(changes)); err != nil {
+			return err
+		}
+		if err := r.audit(ctx, tx, doc.Ref.ID, AuditUpdate, updatedChanges(changes, snapshotValue(doc))); err != nil {
+			return err
+		}
+		created, docID = false, doc.Ref.ID
+		obj.SetDocId(docID)
+		return commit()
+	})
+	if err != nil {
+		return nil, false, r.translateError(docID, err)
+	}
+
+	return &docID, created, nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryRepositoryUpsert(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User")
+
+	id, created, err := repo.Upsert(ctx, &testUser{Name: "A", Email: "a@example.com", Age: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Errorf("Expected the first Upsert to create the user")
+	}
+	first, _ := repo.GetByID(ctx, *id)
+
+	time.Sleep(time.Millisecond)
+	user := &testUser{Name: "B", Email: "a@example.com"}
+	updatedID, created, err := repo.Upsert(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || *updatedID != *id || user.ID != *id {
+		t.Errorf("Expected the user %s to be updated, got %s (created %v)", *id, *updatedID, created)
+	}
+
+	got, err := repo.GetByID(ctx, *id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*got).Name != "B" || (*got).Age != 0 {
+		t.Errorf("Expected the stored user to be replaced, got %+v", *got)
+	}
+	if !(*got).CreatedAt.Equal((*first).CreatedAt) || !user.CreatedAt.Equal((*first).CreatedAt) {
+		t.Errorf("Expected CreatedAt %v to be kept, got %v", (*first).CreatedAt, (*got).CreatedAt)
+	}
+	if !(*got).UpdatedAt.After((*first).UpdatedAt) {
+		t.Errorf("Expected UpdatedAt to be bumped, got %v", (*got).UpdatedAt)
+	}
+	if page, _ := repo.Get(ctx, nil); len(page.Items) != 1 {
+		t.Errorf("Expected a single user, got %d", len(page.Items))
+	}
+
+	if _, created, _ := repo.Upsert(ctx, &testUser{Name: "D", Email: "d@example.com"}); !created {
+		t.Errorf("Expected a user with other unique values to be created")
+	}
+}
+
+func TestUpsertData(t *testing.T) {
+	o := newOptions([]Option{WithSoftDelete()})
+	data := o.upsertData(&testUser{Name: "A", Email: "a@example.com"})
+	if _, ok := data["created_at"]; ok {
+		t.Errorf("Expected CreatedAt to be left out, got %v", data)
+	}
+	if data["name"] != "A" || data["email"] != "a@example.com" {
+		t.Errorf("Expected all other fields, got %v", data)
+	}
+}
@@ -0,0 +1,23 @@
+package repository
+
+import "reflect"
+
+// validate checks obj against its struct tags if a validator is configured.
+func (o *options) validate(obj interface{}) error {
+	if o.validator == nil {
+		return nil
+	}
+	return o.validator.Struct(obj)
+}
+
+// validatePatch checks only the fields of patch that Patch writes.
+func (o *options) validatePatch(patch interface{}, mask []string) error {
+	if o.validator == nil {
+		return nil
+	}
+	val, ok := indirect(reflect.ValueOf(patch))
+	if !ok {
+		return nil
+	}
+	return o.validator.StructPartial(val.Interface(), patchMask(val, mask)...)
+}
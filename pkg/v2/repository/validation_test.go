@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+	"github.com/go-playground/validator/v10"
+)
+
+func TestMemoryRepositoryValidator(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testUser, testUser]("User", WithValidator(validator.New()))
+
+	_, err := repo.Create(ctx, &testUser{Email: "no-email", Name: "A"})
+	if _, status := errors.NewErrorResponse(err); status != 400 {
+		t.Errorf("Expected invalid entity to be reported as 400, got %d: %v", status, err)
+	}
+	if _, err := repo.CreateEasy(ctx, &testUser{Email: "a@example.com"}); err == nil {
+		t.Errorf("Expected CreateEasy to validate")
+	}
+	results, _ := repo.CreateMany(ctx, []*testUser{{Email: "a@example.com"}})
+	if results[0].Err == nil {
+		t.Errorf("Expected CreateMany to validate every entity")
+	}
+	if all, _ := repo.Get(ctx, nil); len(all.Items) != 0 {
+		t.Fatalf("Expected nothing to be written, got %d documents", len(all.Items))
+	}
+
+	id, err := repo.Create(ctx, &testUser{Email: "a@example.com", Name: "A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Patch(ctx, *id, &testUser{Age: 30}, "Age"); err != nil {
+		t.Errorf("Expected Patch to validate only the masked fields, got %v", err)
+	}
+	if _, ok := repo.Patch(ctx, *id, &testUser{Email: "no-email"}, "Email").(validator.ValidationErrors); !ok {
+		t.Errorf("Expected Patch to reject an invalid masked field")
+	}
+	if _, ok := repo.Patch(ctx, *id, &testUser{Age: -1}).(validator.ValidationErrors); !ok {
+		t.Errorf("Expected Patch without mask to validate the non-zero fields")
+	}
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+)
+
+// Versioned is implemented by entities that want to receive their current version when loaded.
+// The version is the integer Version field if the entity has one, otherwise a store specific
+// token such as Firestore's update time. Pass it to UpdateWithVersion to detect lost updates.
+type Versioned interface {
+	SetDocVersion(version string)
+}
+
+const versionFieldName = "Version"
+
+// versionField returns the integer Version field of an entity type.
+func versionField(t reflect.Type) (fieldInfo, bool) {
+	for _, f := range structFields(t) {
+		if f.GoName != versionFieldName || len(f.Index) != 1 {
+			continue
+		}
+		if isIntegerKind(f.Type.Kind()) {
+			return f, true
+		}
+	}
+	return fieldInfo{}, false
+}
+
+func isIntegerKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// entityVersion reads the integer Version field of obj.
+func entityVersion(obj interface{}) (int64, bool) {
+	val, ok := indirect(reflect.ValueOf(obj))
+	if !ok || val.Kind() != reflect.Struct {
+		return 0, false
+	}
+	f, ok := versionField(val.Type())
+	if !ok {
+		return 0, false
+	}
+	fv := val.FieldByIndex(f.Index)
+	if fv.CanInt() {
+		return fv.Int(), true
+	}
+	return int64(fv.Uint()), true
+}
+
+// setInitialVersion starts the integer Version field of a new entity at 1.
+func setInitialVersion(obj interface{}) {
+	val, ok := indirect(reflect.ValueOf(obj))
+	if !ok || val.Kind() != reflect.Struct {
+		return
+	}
+	f, ok := versionField(val.Type())
+	if !ok {
+		return
+	}
+	fv := val.FieldByIndex(f.Index)
+	if fv.CanSet() && fv.IsZero() {
+		fv.Set(reflect.ValueOf(1).Convert(fv.Type()))
+	}
+}
+
+// prepareCreate applies the fields every repository stamps on new entities.
+func prepareCreate(obj interface{}) {
+	setTimestamps(obj, "CreatedAt", "UpdatedAt")
+	setInitialVersion(obj)
+}
+
+// setDocVersion hands the version to entities implementing Versioned.
+func setDocVersion(obj interface{}, version string) {
+	if v, ok := obj.(Versioned); ok {
+		v.SetDocVersion(version)
+	}
+}
+
+// ETag formats a version as a strong HTTP entity tag.
+func ETag(version string) string {
+	return strconv.Quote(version)
+}
+
+// VersionFromETag extracts the version from an If-Match or ETag header value.
+func VersionFromETag(etag string) string {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	if unquoted, err := strconv.Unquote(etag); err == nil {
+		return unquoted
+	}
+	return etag
+}
+
+func preconditionFailed(ressource, id, version string) error {
+	return &errors.ErrorPreconditionFailed{
+		ErrorDetail: errors.ErrorDetail{
+			Resource: ressource,
+			Field:    "id",
+			Value:    id,
+			Message:  ressource + " with id " + id + " does not have version " + version,
+		},
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/errors"
+)
+
+type testVersionedUser struct {
+	testUser
+	Version int64 `firestore:"version"`
+	version string
+}
+
+func (u *testVersionedUser) SetDocVersion(version string) {
+	u.version = version
+}
+
+func TestETag(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{ETag("3"), "3"},
+		{`W/"2024-01-02T03:04:05Z"`, "2024-01-02T03:04:05Z"},
+		{"7", "7"},
+	}
+	for _, tt := range tests {
+		if got := VersionFromETag(tt.header); got != tt.want {
+			t.Errorf("VersionFromETag(%s) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryRepositoryUpdateWithVersion(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("integer version field", func(t *testing.T) {
+		repo := NewMemoryRepository[*testVersionedUser, testVersionedUser]("User")
+		user := &testVersionedUser{testUser: testUser{Email: "a@example.com"}}
+		id, err := repo.Create(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Version != 1 {
+			t.Errorf("Expected version 1 after create, got %d", user.Version)
+		}
+
+		got, _ := repo.GetByID(ctx, *id)
+		if (*got).version != "1" {
+			t.Fatalf("Expected loaded version 1, got %q", (*got).version)
+		}
+		if err := repo.UpdateWithVersion(ctx, *id, (*got).version, map[string]interface{}{"name": "A"}); err != nil {
+			t.Fatal(err)
+		}
+		err = repo.UpdateWithVersion(ctx, *id, (*got).version, map[string]interface{}{"name": "B"})
+		if _, ok := err.(*errors.ErrorPreconditionFailed); !ok {
+			t.Errorf("Expected ErrorPreconditionFailed for a stale version, got %v", err)
+		}
+
+		if err := repo.Update(ctx, *id, map[string]interface{}{"name": "C"}); err != nil {
+			t.Fatal(err)
+		}
+		got, _ = repo.GetByID(ctx, *id)
+		if (*got).Version != 3 || (*got).Name != "C" {
+			t.Errorf("Expected version 3 and name C, got %d %s", (*got).Version, (*got).Name)
+		}
+	})
+
+	t.Run("revision", func(t *testing.T) {
+		repo := NewMemoryRepository[*testUser, testUser]("User")
+		ids := seedUsers(t, repo, &testUser{Email: "a@example.com"})
+
+		if err := repo.UpdateWithVersion(ctx, ids[0], "1", map[string]interface{}{"name": "A"}); err != nil {
+			t.Fatal(err)
+		}
+		err := repo.UpdateWithVersion(ctx, ids[0], "1", map[string]interface{}{"name": "B"})
+		if _, ok := err.(*errors.ErrorPreconditionFailed); !ok {
+			t.Errorf("Expected ErrorPreconditionFailed for a stale version, got %v", err)
+		}
+	})
+
+	t.Run("error response", func(t *testing.T) {
+		_, code := errors.NewErrorResponse(preconditionFailed("User", "1", "2"))
+		if code != 412 {
+			t.Errorf("Expected 412, got %d", code)
+		}
+	})
+}
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"iter"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WatchEventKind tells how a document changed.
+type WatchEventKind int
+
+const (
+	WatchAdded WatchEventKind = iota + 1
+	WatchModified
+	WatchRemoved
+)
+
+func (k WatchEventKind) String() string {
+	switch k {
+	case WatchAdded:
+		return "added"
+	case WatchModified:
+		return "modified"
+	case WatchRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// WatchEvent is one change of a watched query. Item is the document after the change, or its
+// last known state if it was removed from the results.
+type WatchEvent[T any] struct {
+	Kind WatchEventKind
+	ID   string
+	Item T
+}
+
+const (
+	watchMinBackoff = 500 * time.Millisecond
+	watchMaxBackoff = 30 * time.Second
+)
+
+// watchState is the last known version of a watched document.
+type watchState[T any] struct {
+	id      string
+	version string
+	obj     T
+}
+
+// diffSnapshot returns the events that turn known into the documents of current and updates known.
+// Documents missing from current are reported as removed, in ID order.
+func diffSnapshot[T any](known map[string]watchState[T], current []watchState[T]) []WatchEvent[T] {
+	var events []WatchEvent[T]
+	seen := make(map[string]bool, len(current))
+	for _, doc := range current {
+		seen[doc.id] = true
+		before, ok := known[doc.id]
+		switch {
+		case !ok:
+			events = append(events, WatchEvent[T]{Kind: WatchAdded, ID: doc.id, Item: doc.obj})
+		case before.version != doc.version:
+			events = append(events, WatchEvent[T]{Kind: WatchModified, ID: doc.id, Item: doc.obj})
+		default:
+			continue
+		}
+		known[doc.id] = doc
+	}
+
+	var removed []string
+	for id := range known {
+		if !seen[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(removed)
+	for _, id := range removed {
+		events = append(events, WatchEvent[T]{Kind: WatchRemoved, ID: id, Item: known[id].obj})
+		delete(known, id)
+	}
+	return events
+}
+
+// Watch streams the changes of the documents matching the filters, order and limit of opts.
+// The first events add every matching document. Interrupted listeners reconnect with backoff
+// and only report what changed in between. Iteration ends when ctx is cancelled or with an
+// error that cannot be retried, which is yielded with a zero event.
+func (r *repository[T, TT]) Watch(ctx context.Context, opts *query.QueryOptions) iter.Seq2[WatchEvent[T], error] {
+	return func(yield func(WatchEvent[T], error) bool) {
+		if opts == nil {
+			opts = &query.QueryOptions{}
+		}
+		sealed, err := sealQuery[T](r.opts, r.Ressource, opts)
+		if err != nil {
+			yield(WatchEvent[T]{}, err)
+			return
+		}
+		q := r.filter(r.query(), sealed)
+		if opts.Limit > 0 {
+			q = q.Limit(opts.Limit)
+		}
+
+		known := make(map[string]watchState[T])
+		backoff := watchMinBackoff
+		for {
+			it := q.Snapshots(ctx)
+			err := r.listen(ctx, it, known, func(event WatchEvent[T]) bool {
+				backoff = watchMinBackoff
+				return yield(event, nil)
+			})
+			it.Stop()
+			if err == nil || ctx.Err() != nil {
+				return
+			}
+			if !retryWatch(err) {
+				yield(WatchEvent[T]{}, r.translateError("", err))
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, watchMaxBackoff)
+		}
+	}
+}
+
+// listen emits the events of one snapshot listener until emit returns false, which ends it with
+// a nil error. Its first snapshot is compared with known, so a reconnect skips unchanged documents.
+func (r *repository[T, TT]) listen(ctx context.Context, it *firestore.QuerySnapshotIterator, known map[string]watchState[T], emit func(WatchEvent[T]) bool) error {
+	first := true
+	for {
+		snap, err := it.Next()
+		if err != nil {
+			return err
+		}
+
+		var events []WatchEvent[T]
+		if first {
+			docs, err := snap.Documents.GetAll()
+			if err != nil {
+				return err
+			}
+			current := make([]watchState[T], 0, len(docs))
+			for _, doc := range docs {
+				state, err := r.watchState(ctx, doc)
+				if err != nil {
+					return err
+				}
+				current = append(current, state)
+			}
+			events = diffSnapshot(known, current)
+			first = false
+		} else {
+			for _, change := range snap.Changes {
+				state, err := r.watchState(ctx, change.Doc)
+				if err != nil {
+					return err
+				}
+				switch change.Kind {
+				case firestore.DocumentAdded:
+					known[state.id] = state
+					events = append(events, WatchEvent[T]{Kind: WatchAdded, ID: state.id, Item: state.obj})
+				case firestore.DocumentModified:
+					known[state.id] = state
+					events = append(events, WatchEvent[T]{Kind: WatchModified, ID: state.id, Item: state.obj})
+				case firestore.DocumentRemoved:
+					delete(known, state.id)
+					events = append(events, WatchEvent[T]{Kind: WatchRemoved, ID: state.id, Item: state.obj})
+				}
+			}
+		}
+
+		for _, event := range events {
+			if !emit(event) {
+				return nil
+			}
+		}
+	}
+}
+
+func (r *repository[T, TT]) watchState(ctx context.Context, doc *firestore.DocumentSnapshot) (watchState[T], error) {
+	obj, err := r.decode(ctx, doc)
+	if err != nil {
+		return watchState[T]{}, err
+	}
+	return watchState[T]{id: doc.Ref.ID, version: doc.UpdateTime.UTC().Format(time.RFC3339Nano), obj: *obj}, nil
+}
+
+// retryWatch reports whether a snapshot listener failed for a transient reason.
+func retryWatch(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch st.Code() {
+	case codes.Unavailable, codes.Internal, codes.Unknown, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Talk-Point/go-webtoolkit/pkg/v2/query"
+)
+
+func TestDiffSnapshot(t *testing.T) {
+	known := map[string]watchState[string]{}
+	events := diffSnapshot(known, []watchState[string]{{id: "a", version: "1", obj: "A"}, {id: "b", version: "1", obj: "B"}})
+	if len(events) != 2 || events[0].Kind != WatchAdded || events[1].Kind != WatchAdded {
+		t.Fatalf("Expected two added events, got %+v", events)
+	}
+
+	events = diffSnapshot(known, []watchState[string]{{id: "a", version: "2", obj: "A2"}, {id: "b", version: "1", obj: "B"}})
+	if len(events) != 1 || events[0].Kind != WatchModified || events[0].Item != "A2" {
+		t.Fatalf("Expected a to be modified, got %+v", events)
+	}
+
+	events = diffSnapshot(known, []watchState[string]{{id: "a", version: "2", obj: "A2"}})
+	if len(events) != 1 || events[0].Kind != WatchRemoved || events[0].ID != "b" || events[0].Item != "B" {
+		t.Fatalf("Expected b to be removed with its last state, got %+v", events)
+	}
+	if _, ok := known["b"]; ok {
+		t.Errorf("Expected removed documents to be forgotten")
+	}
+}
+
+func TestMemoryRepositoryWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := NewMemoryRepository[*testUser, testUser]("User", WithSoftDelete())
+
+	first, err := repo.Create(ctx, &testUser{Name: "A", Email: "a@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan WatchEvent[*testUser])
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event, err := range repo.Watch(ctx, &query.QueryOptions{
+			Filters: []query.Filter{{Field: "name", Operator: query.Eq, Value: "A"}},
+		}) {
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			events <- event
+		}
+	}()
+	next := func(kind WatchEventKind, id string) WatchEvent[*testUser] {
+		t.Helper()
+		select {
+		case event := <-events:
+			if event.Kind != kind || event.ID != id {
+				t.Fatalf("Expected %s event for %s, got %s for %s", kind, id, event.Kind, event.ID)
+			}
+			return event
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %s event for %s", kind, id)
+		}
+		return WatchEvent[*testUser]{}
+	}
+
+	if event := next(WatchAdded, *first); event.Item.ID != *first {
+		t.Errorf("Expected the item to carry its ID, got %q", event.Item.ID)
+	}
+
+	if _, err := repo.Create(ctx, &testUser{Name: "B", Email: "b@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	second, err := repo.Create(ctx, &testUser{Name: "A", Email: "c@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next(WatchAdded, *second)
+
+	if err := repo.Update(ctx, *first, map[string]interface{}{"email": "d@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(WatchModified, *first); event.Item.Email != "d@example.com" {
+		t.Errorf("Expected the modified item, got %+v", event.Item)
+	}
+
+	if err := repo.Delete(ctx, *second); err != nil {
+		t.Fatal(err)
+	}
+	next(WatchRemoved, *second)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Watch to stop when the context is cancelled")
+	}
+}